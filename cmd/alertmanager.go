@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// defaultAlertStateFile records which message shows which alert.
+const defaultAlertStateFile = "alertmanager-state.json"
+
+// alertmanagerWebhookVersion is the only webhook payload version accepted.
+const alertmanagerWebhookVersion = "4"
+
+const (
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// AlertmanagerConfig enables the Alertmanager receiver of the relay.
+type AlertmanagerConfig struct {
+	// Channel is used when no route matches; it defaults to the channel
+	// of the server.
+	Channel string       `yaml:"channel"`
+	Routes  []AlertRoute `yaml:"routes"`
+	// StateFile records the message posted for every alert fingerprint.
+	StateFile string `yaml:"state_file"`
+}
+
+// AlertRoute sends the alerts of a receiver, or the alerts carrying all of
+// the labels, to a channel. Routes are tried in order.
+type AlertRoute struct {
+	Receiver string            `yaml:"receiver"`
+	Labels   map[string]string `yaml:"labels"`
+	Channel  string            `yaml:"channel"`
+}
+
+// matches reports whether the route applies to the notification.
+func (r AlertRoute) matches(n *AlertmanagerNotification) bool {
+	if r.Receiver != "" && r.Receiver != n.Receiver {
+		return false
+	}
+	for name, value := range r.Labels {
+		if n.CommonLabels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// AlertmanagerNotification is the version 4 webhook payload of Alertmanager.
+type AlertmanagerNotification struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// Alert is a single alert of a notification.
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// alertReceiver posts Alertmanager notifications and updates the message
+// once the alerts resolve.
+type alertReceiver struct {
+	config  *AlertmanagerConfig
+	channel string
+	poster  messagePoster
+	updater messageUpdater
+	store   MessageStore
+
+	// mu serializes notifications, so that concurrent ones for the same
+	// alerts do not post twice.
+	mu sync.Mutex
+}
+
+func newAlertReceiver(config *AlertmanagerConfig, channel string, poster messagePoster, updater messageUpdater) *alertReceiver {
+	if config.Channel != "" {
+		channel = config.Channel
+	}
+	path := config.StateFile
+	if path == "" {
+		path = defaultAlertStateFile
+	}
+	return &alertReceiver{
+		config:  config,
+		channel: channel,
+		poster:  poster,
+		updater: updater,
+		store:   newFileStore(path),
+	}
+}
+
+// route returns the channel for the notification.
+func (a *alertReceiver) route(n *AlertmanagerNotification) string {
+	for _, r := range a.config.Routes {
+		if r.matches(n) {
+			return r.Channel
+		}
+	}
+	return a.channel
+}
+
+// Notify posts the notification, or updates the message that already shows
+// one of its alerts. Once every alert has resolved the fingerprints are
+// forgotten, so that the alerts get a new message when they fire again.
+func (a *alertReceiver) Notify(channel string, n *AlertmanagerNotification) (slack.MessageRef, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var ref slack.MessageRef
+	found := false
+	for _, alert := range n.Alerts {
+		r, ok, err := a.store.Get(alert.Fingerprint)
+		if err != nil {
+			return ref, fmt.Errorf("error while looking up alert %s: %v", alert.Fingerprint, err)
+		}
+		if ok {
+			ref, found = r, true
+			break
+		}
+	}
+
+	message := AlertMessage(n, channel)
+	if found {
+		err := a.updater.UpdateMessage(ref, message)
+		if err != nil && !IsSlackError(err, "message_not_found") {
+			return ref, fmt.Errorf("error while updating alert message: %w", err)
+		}
+		found = err == nil
+	}
+	if !found {
+		if n.Status == AlertResolved {
+			// Nothing was posted for these alerts, or the message is gone;
+			// a lone resolved notice is still worth showing.
+			logger.Infof("no message recorded for resolved alerts of %s, posting one", n.Receiver)
+		}
+		var err error
+		if ref, err = a.poster.AddFormattedMessage(channel, message); err != nil {
+			return ref, fmt.Errorf("error while sending message to slack: %w", err)
+		}
+	}
+
+	for _, alert := range n.Alerts {
+		var err error
+		if n.Status == AlertResolved {
+			err = a.store.Delete(alert.Fingerprint)
+		} else {
+			err = a.store.Put(alert.Fingerprint, ref)
+		}
+		if err != nil {
+			return ref, fmt.Errorf("error while recording alert %s: %v", alert.Fingerprint, err)
+		}
+	}
+	return ref, nil
+}
+
+// alertAnnotations are shown as text or buttons, not as fields.
+var alertAnnotations = []string{"summary", "description", "runbook_url"}
+
+// AlertMessage renders a notification: the title counts the firing alerts,
+// the text lists the firing and the resolved alerts by summary, the common
+// labels become fields and the runbook and source links buttons. Labels and
+// annotations are escaped, so that they cannot mention or link in Slack.
+func AlertMessage(n *AlertmanagerNotification, channel string) slack.Message {
+	var firing, resolved []Alert
+	for _, alert := range n.Alerts {
+		if alert.Status == AlertResolved {
+			resolved = append(resolved, alert)
+		} else {
+			firing = append(firing, alert)
+		}
+	}
+
+	name := n.CommonLabels["alertname"]
+	if name == "" {
+		name = n.GroupLabels["alertname"]
+	}
+	if name == "" {
+		name = n.Receiver
+	}
+	title := fmt.Sprintf("[RESOLVED] %s", name)
+	if len(firing) > 0 {
+		title = fmt.Sprintf("[FIRING:%d] %s", len(firing)+n.TruncatedAlerts, name)
+	}
+
+	var text strings.Builder
+	if summary := n.CommonAnnotations["summary"]; summary != "" {
+		text.WriteString(slackEscaper.Replace(summary) + "\n")
+	}
+	if description := n.CommonAnnotations["description"]; description != "" {
+		text.WriteString(slackEscaper.Replace(description) + "\n")
+	}
+	for _, group := range []struct {
+		heading string
+		alerts  []Alert
+	}{
+		{"Firing", firing},
+		{"Resolved", resolved},
+	} {
+		if len(group.alerts) == 0 {
+			continue
+		}
+		fmt.Fprintf(&text, "\n*%s*\n", group.heading)
+		for _, alert := range group.alerts {
+			fmt.Fprintf(&text, "• %s\n", slackEscaper.Replace(alertLine(alert, n)))
+		}
+	}
+	if n.TruncatedAlerts > 0 {
+		fmt.Fprintf(&text, "\n_%d more alerts not shown_\n", n.TruncatedAlerts)
+	}
+
+	message := SlackMessageBuilder(title, truncate(strings.TrimSpace(text.String()), maxSectionTextLength), channel)
+	message.Blocks = append(message.Blocks, FieldsBlocks(alertFields(n))...)
+	message.Blocks = append(message.Blocks, ButtonsBlocks(alertButtons(n))...)
+	return message
+}
+
+// alertLine describes an alert by its summary, or its labels that are not
+// shared by the whole group.
+func alertLine(alert Alert, n *AlertmanagerNotification) string {
+	line := alert.Annotations["summary"]
+	if line == "" || line == n.CommonAnnotations["summary"] {
+		var labels []string
+		for _, name := range sortedKeys(alert.Labels) {
+			if _, common := n.CommonLabels[name]; !common {
+				labels = append(labels, fmt.Sprintf("%s=%s", name, alert.Labels[name]))
+			}
+		}
+		if len(labels) > 0 {
+			line = strings.Join(labels, " ")
+		} else if line == "" {
+			line = alert.Labels["alertname"]
+		}
+	}
+	if alert.Status == AlertResolved && !alert.EndsAt.IsZero() {
+		return fmt.Sprintf("%s (resolved %s)", line, alert.EndsAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	if !alert.StartsAt.IsZero() {
+		return fmt.Sprintf("%s (since %s)", line, alert.StartsAt.UTC().Format("2006-01-02 15:04 MST"))
+	}
+	return line
+}
+
+func alertFields(n *AlertmanagerNotification) Fields {
+	var fields Fields
+	for _, name := range sortedKeys(n.CommonLabels) {
+		if name == "alertname" {
+			continue
+		}
+		fields = append(fields, Field{Title: slackEscaper.Replace(name), Value: slackEscaper.Replace(n.CommonLabels[name])})
+	}
+	return fields
+}
+
+func alertButtons(n *AlertmanagerNotification) Buttons {
+	var buttons Buttons
+	runbook := n.CommonAnnotations["runbook_url"]
+	if runbook == "" && len(n.Alerts) > 0 {
+		runbook = n.Alerts[0].Annotations["runbook_url"]
+	}
+	if runbook != "" {
+		buttons = append(buttons, Button{Text: "Runbook", URL: slackEscaper.Replace(runbook)})
+	}
+	if len(n.Alerts) > 0 && n.Alerts[0].GeneratorURL != "" {
+		buttons = append(buttons, Button{Text: "Source", URL: slackEscaper.Replace(n.Alerts[0].GeneratorURL)})
+	}
+	if n.ExternalURL != "" {
+		buttons = append(buttons, Button{Text: "Alertmanager", URL: slackEscaper.Replace(n.ExternalURL)})
+	}
+	return buttons
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// handleAlertmanager receives Alertmanager webhooks. Alertmanager sends the
+// client's API key through the authorization of its http_config.
+func (s *relayServer) handleAlertmanager(w http.ResponseWriter, r *http.Request) {
+	body, client, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var notification AlertmanagerNotification
+	if err := json.Unmarshal(body, &notification); err != nil {
+		writeRelayError(w, http.StatusBadRequest, fmt.Sprintf("invalid notification: %v", err))
+		return
+	}
+	if notification.Version != alertmanagerWebhookVersion {
+		writeRelayError(w, http.StatusBadRequest, fmt.Sprintf("unsupported webhook version %q, expected %s", notification.Version, alertmanagerWebhookVersion))
+		return
+	}
+	if len(notification.Alerts) == 0 {
+		writeRelayError(w, http.StatusBadRequest, "notification has no alerts")
+		return
+	}
+	channel := s.alerts.route(&notification)
+	switch {
+	case channel == "":
+		writeRelayError(w, http.StatusUnprocessableEntity, fmt.Sprintf("no channel for receiver %s", notification.Receiver))
+		return
+	case !client.allows(channel):
+		writeRelayError(w, http.StatusForbidden, fmt.Sprintf("client %s may not post to %s", client.Name, channel))
+		return
+	}
+
+	ref, err := s.alerts.Notify(channel, &notification)
+	if err != nil {
+		logger.Errorf("client %s: error while sending alerts to %s: %v", client.Name, channel, err)
+		writeRelayError(w, relayErrorStatus(err), err.Error())
+		return
+	}
+	logger.Infof("client %s: %d %s alerts of %s in %s", client.Name, len(notification.Alerts), notification.Status, notification.Receiver, ref.Channel)
+	writeRelayResponse(w, http.StatusOK, relayResponse{OK: true, Channel: ref.Channel, TS: ref.Timestamp})
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testAlertNotification(status string) *AlertmanagerNotification {
+	startsAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	alert := func(fingerprint string, instance string) Alert {
+		a := Alert{
+			Status:       status,
+			Labels:       map[string]string{"alertname": "HighLatency", "severity": "critical", "instance": instance},
+			Annotations:  map[string]string{"summary": "Latency above 2s", "runbook_url": "https://runbooks.example.com/latency"},
+			StartsAt:     startsAt,
+			GeneratorURL: "https://prometheus.example.com/graph",
+			Fingerprint:  fingerprint,
+		}
+		if status == AlertResolved {
+			a.EndsAt = startsAt.Add(time.Hour)
+		}
+		return a
+	}
+	return &AlertmanagerNotification{
+		Version:           "4",
+		GroupKey:          `{}:{alertname="HighLatency"}`,
+		Status:            status,
+		Receiver:          "team-db",
+		GroupLabels:       map[string]string{"alertname": "HighLatency"},
+		CommonLabels:      map[string]string{"alertname": "HighLatency", "severity": "critical"},
+		CommonAnnotations: map[string]string{"summary": "Latency above 2s", "runbook_url": "https://runbooks.example.com/latency"},
+		ExternalURL:       "https://alertmanager.example.com",
+		Alerts:            []Alert{alert("a1", "db1:9100"), alert("a2", "db2:9100")},
+	}
+}
+
+func TestAlertMessage(t *testing.T) {
+	firing := testAlertNotification(AlertFiring)
+	firing.Alerts[1].Status = AlertResolved
+	firing.Alerts[1].EndsAt = firing.Alerts[1].StartsAt.Add(time.Hour)
+
+	message := AlertMessage(firing, "alerts")
+	if title := message.Blocks[0].Text.Text; title != "[FIRING:1] HighLatency" {
+		t.Errorf("Unexpected title %q", title)
+	}
+	text := message.Blocks[1].Text.Text
+	for _, want := range []string{"Latency above 2s", "*Firing*\n• instance=db1:9100 (since 2024-03-01 12:00 UTC)", "*Resolved*\n• instance=db2:9100 (resolved 2024-03-01 13:00 UTC)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected text to contain %q, got %q", want, text)
+		}
+	}
+	if fields := message.Blocks[2].Fields; len(fields) != 1 || fields[0].Text != "*severity*\ncritical" {
+		t.Errorf("Expected the common labels as fields, got %+v", fields)
+	}
+	buttons := message.Blocks[len(message.Blocks)-1].Text.Text
+	for _, want := range []string{"Runbook", "Source", "Alertmanager"} {
+		if !strings.Contains(buttons, want) {
+			t.Errorf("Expected a %s button, got %q", want, buttons)
+		}
+	}
+
+	payload, _ := json.Marshal(message)
+	if problems := ValidateBlockKit(payload); len(problems) > 0 {
+		t.Errorf("Expected valid Block Kit, got %v", problems)
+	}
+
+	large := testAlertNotification(AlertFiring)
+	large.CommonAnnotations["description"] = strings.Repeat("Queries on the primary are slow. ", 40)
+	for i := 0; i < 200; i++ {
+		alert := large.Alerts[0]
+		alert.Labels = map[string]string{"alertname": "HighLatency", "severity": "critical", "instance": fmt.Sprintf("db%d:9100", i)}
+		alert.Annotations = map[string]string{"summary": fmt.Sprintf("Latency of db%d above 2s", i)}
+		large.Alerts = append(large.Alerts, alert)
+	}
+	payload, _ = json.Marshal(AlertMessage(large, "alerts"))
+	if problems := ValidateBlockKit(payload); len(problems) > 0 {
+		t.Errorf("Expected a large alert group to be truncated to valid Block Kit, got %v", problems)
+	}
+
+	escaped := testAlertNotification(AlertFiring)
+	escaped.CommonAnnotations["summary"] = "<!channel> latency > 2s & rising"
+	escaped.CommonLabels["team"] = "<@U123>"
+	escaped.Alerts[0].Annotations["summary"] = "see <https://evil.example.com|the docs>"
+	message = AlertMessage(escaped, "alerts")
+	text = message.Blocks[1].Text.Text
+	for _, want := range []string{"&lt;!channel&gt; latency &gt; 2s &amp; rising", "• see &lt;https://evil.example.com|the docs&gt;"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected text to contain %q, got %q", want, text)
+		}
+	}
+	if fields := message.Blocks[2].Fields; len(fields) != 2 || fields[1].Text != "*team*\n&lt;@U123&gt;" {
+		t.Errorf("Expected the labels to be escaped, got %+v", fields)
+	}
+
+	resolved := AlertMessage(testAlertNotification(AlertResolved), "alerts")
+	if title := resolved.Blocks[0].Text.Text; title != "[RESOLVED] HighLatency" {
+		t.Errorf("Unexpected title %q", title)
+	}
+}
+
+func TestAlertRoutes(t *testing.T) {
+	receiver := newAlertReceiver(&AlertmanagerConfig{
+		Channel: "alerts",
+		Routes: []AlertRoute{
+			{Labels: map[string]string{"severity": "critical", "team": "db"}, Channel: "db-oncall"},
+			{Receiver: "team-db", Channel: "db"},
+			{Labels: map[string]string{"severity": "critical"}, Channel: "oncall"},
+		},
+	}, "general", nil, nil)
+
+	tests := []struct {
+		name     string
+		receiver string
+		labels   map[string]string
+		want     string
+	}{
+		{"All labels", "team-web", map[string]string{"severity": "critical", "team": "db"}, "db-oncall"},
+		{"Receiver", "team-db", map[string]string{"severity": "critical"}, "db"},
+		{"Label", "team-web", map[string]string{"severity": "critical"}, "oncall"},
+		{"Default", "team-web", map[string]string{"severity": "warning"}, "alerts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := &AlertmanagerNotification{Receiver: tt.receiver, CommonLabels: tt.labels}
+			if got := receiver.route(n); got != tt.want {
+				t.Errorf("Expected channel %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAlertmanagerReceiver(t *testing.T) {
+	config := testServerConfig()
+	config.Alertmanager = &AlertmanagerConfig{
+		StateFile: filepath.Join(t.TempDir(), "alerts.json"),
+		Routes:    []AlertRoute{{Receiver: "team-db", Channel: "general"}},
+	}
+	server, fake := newTestRelay(t, config)
+	url := server.URL + "/v1/alertmanager"
+	headers := map[string]string{"Authorization": "Bearer jenkins-key"}
+	send := func(n *AlertmanagerNotification) (int, relayResponse) {
+		body, _ := json.Marshal(n)
+		return relayPost(t, url, string(body), headers)
+	}
+
+	status, fired := send(testAlertNotification(AlertFiring))
+	if status != http.StatusOK || fired.Channel != "C0GENERAL" {
+		t.Fatalf("Expected the alerts to be posted, got %d %+v", status, fired)
+	}
+
+	// Alertmanager repeats firing notifications; they update the message.
+	if _, repeated := send(testAlertNotification(AlertFiring)); repeated.TS != fired.TS {
+		t.Errorf("Expected the repeated notification to update %s, got %+v", fired.TS, repeated)
+	}
+
+	status, resolved := send(testAlertNotification(AlertResolved))
+	if status != http.StatusOK || resolved.TS != fired.TS {
+		t.Fatalf("Expected the resolved alerts to update %s, got %d %+v", fired.TS, status, resolved)
+	}
+	messages := fake.channelMessages("C0GENERAL")
+	if len(messages) != 1 {
+		t.Fatalf("Expected a single message, got %d", len(messages))
+	}
+	if updates := fake.requestsFor("chat.update"); len(updates) != 2 || !strings.Contains(updates[1].param("blocks"), "[RESOLVED] HighLatency") {
+		t.Errorf("Expected the message to be updated to resolved, got %+v", updates)
+	}
+
+	// Once resolved, the alerts get a new message when they fire again.
+	if _, refired := send(testAlertNotification(AlertFiring)); refired.TS == fired.TS {
+		t.Errorf("Expected a new message, got %+v", refired)
+	}
+
+	unsupported := testAlertNotification(AlertFiring)
+	unsupported.Version = "3"
+	if status, _ := send(unsupported); status != http.StatusBadRequest {
+		t.Errorf("Expected version 3 to be rejected, got %d", status)
+	}
+	if status, _ := relayPost(t, url, "{}", nil); status != http.StatusUnauthorized {
+		t.Errorf("Expected unauthenticated webhooks to be rejected, got %d", status)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Block Kit limits, from https://api.slack.com/reference/block-kit.
+const (
+	maxMessageBlocks     = 50
+	maxBlockIDLength     = 255
+	maxHeaderTextLength  = 150
+	maxSectionTextLength = 3000
+	maxFieldTextLength   = 2000
+	maxContextElements   = 10
+	maxActionsElements   = 25
+	maxButtonTextLength  = 75
+	maxURLLength         = 3000
+)
+
+// ValidateBlockKit checks a message payload, as sent to chat.postMessage,
+// against the Block Kit constraints Slack enforces. It returns one error per
+// violation, each prefixed with the path of the offending value.
+func ValidateBlockKit(payload []byte) []error {
+	var message struct {
+		Blocks []map[string]any `json:"blocks"`
+	}
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return []error{fmt.Errorf("invalid payload: %v", err)}
+	}
+
+	v := &blockKitValidator{blockIDs: map[string]bool{}}
+	if len(message.Blocks) > maxMessageBlocks {
+		v.fail("blocks", "has %d blocks, at most %d are allowed", len(message.Blocks), maxMessageBlocks)
+	}
+	for i, block := range message.Blocks {
+		v.block(fmt.Sprintf("blocks[%d]", i), block)
+	}
+	return v.errs
+}
+
+type blockKitValidator struct {
+	errs     []error
+	blockIDs map[string]bool
+}
+
+func (v *blockKitValidator) fail(path string, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *blockKitValidator) block(path string, block map[string]any) {
+	if id, ok := block["block_id"].(string); ok {
+		if utf8.RuneCountInString(id) > maxBlockIDLength {
+			v.fail(path+".block_id", "longer than %d characters", maxBlockIDLength)
+		}
+		if v.blockIDs[id] {
+			v.fail(path+".block_id", "%q is not unique", id)
+		}
+		v.blockIDs[id] = true
+	}
+
+	switch block["type"] {
+	case "header":
+		v.text(path+".text", block["text"], maxHeaderTextLength, "plain_text")
+	case "section":
+		_, hasText := block["text"]
+		fields, _ := block["fields"].([]any)
+		if !hasText && len(fields) == 0 {
+			v.fail(path, "section needs text or fields")
+		}
+		if hasText {
+			v.text(path+".text", block["text"], maxSectionTextLength, "plain_text", "mrkdwn")
+		}
+		if len(fields) > maxSectionFields {
+			v.fail(path+".fields", "has %d fields, at most %d are allowed", len(fields), maxSectionFields)
+		}
+		for i, field := range fields {
+			v.text(fmt.Sprintf("%s.fields[%d]", path, i), field, maxFieldTextLength, "plain_text", "mrkdwn")
+		}
+	case "context":
+		elements, _ := block["elements"].([]any)
+		if len(elements) == 0 || len(elements) > maxContextElements {
+			v.fail(path+".elements", "has %d elements, expected 1 to %d", len(elements), maxContextElements)
+		}
+		for i, element := range elements {
+			elementPath := fmt.Sprintf("%s.elements[%d]", path, i)
+			if e, _ := element.(map[string]any); e != nil && e["type"] == "image" {
+				v.image(elementPath, e)
+				continue
+			}
+			v.text(elementPath, element, maxSectionTextLength, "plain_text", "mrkdwn")
+		}
+	case "actions":
+		elements, _ := block["elements"].([]any)
+		if len(elements) == 0 || len(elements) > maxActionsElements {
+			v.fail(path+".elements", "has %d elements, expected 1 to %d", len(elements), maxActionsElements)
+		}
+		for i, element := range elements {
+			e, _ := element.(map[string]any)
+			if e != nil && e["type"] == "button" {
+				v.button(fmt.Sprintf("%s.elements[%d]", path, i), e)
+			}
+		}
+	case "image":
+		v.image(path, block)
+	case "divider", "rich_text", "input", "file", "video":
+	default:
+		v.fail(path+".type", "unknown block type %v", block["type"])
+	}
+}
+
+// text checks a text object of one of the given types.
+func (v *blockKitValidator) text(path string, value any, maxLength int, types ...string) {
+	text, _ := value.(map[string]any)
+	if text == nil {
+		v.fail(path, "text object is missing")
+		return
+	}
+	typeOK := false
+	for _, t := range types {
+		typeOK = typeOK || text["type"] == t
+	}
+	if !typeOK {
+		v.fail(path+".type", "is %v, expected one of %v", text["type"], types)
+	}
+	if _, ok := text["emoji"]; ok && text["type"] != "plain_text" {
+		v.fail(path+".emoji", "is only allowed on plain_text")
+	}
+	s, _ := text["text"].(string)
+	if s == "" {
+		v.fail(path+".text", "must not be empty")
+	}
+	if n := utf8.RuneCountInString(s); n > maxLength {
+		v.fail(path+".text", "has %d characters, at most %d are allowed", n, maxLength)
+	}
+}
+
+func (v *blockKitValidator) image(path string, image map[string]any) {
+	url, _ := image["image_url"].(string)
+	if url == "" {
+		v.fail(path+".image_url", "is required")
+	} else if len(url) > maxURLLength {
+		v.fail(path+".image_url", "longer than %d characters", maxURLLength)
+	}
+	if alt, _ := image["alt_text"].(string); alt == "" {
+		v.fail(path+".alt_text", "is required")
+	}
+}
+
+func (v *blockKitValidator) button(path string, button map[string]any) {
+	v.text(path+".text", button["text"], maxButtonTextLength, "plain_text")
+	if url, _ := button["url"].(string); len(url) > maxURLLength {
+		v.fail(path+".url", "longer than %d characters", maxURLLength)
+	}
+	if style, ok := button["style"]; ok && style != "primary" && style != "danger" {
+		v.fail(path+".style", "is %v, expected primary or danger", style)
+	}
+}
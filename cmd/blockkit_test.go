@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateBlockKit(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		expected []string
+	}{
+		{
+			name:    "Valid message",
+			payload: `{"blocks":[{"type":"header","text":{"type":"plain_text","text":"Deploy"}},{"type":"section","text":{"type":"mrkdwn","text":"*done*"}},{"type":"divider"}]}`,
+		},
+		{
+			name:     "Header too long",
+			payload:  `{"blocks":[{"type":"header","text":{"type":"plain_text","text":"` + strings.Repeat("é", 151) + `"}}]}`,
+			expected: []string{"blocks[0].text.text: has 151 characters"},
+		},
+		{
+			name:     "Header with mrkdwn",
+			payload:  `{"blocks":[{"type":"header","text":{"type":"mrkdwn","text":"*Deploy*"}}]}`,
+			expected: []string{"blocks[0].text.type: is mrkdwn"},
+		},
+		{
+			name:     "Empty section",
+			payload:  `{"blocks":[{"type":"section"}]}`,
+			expected: []string{"blocks[0]: section needs text or fields"},
+		},
+		{
+			name:     "Empty text",
+			payload:  `{"blocks":[{"type":"section","text":{"type":"mrkdwn","text":""}}]}`,
+			expected: []string{"blocks[0].text.text: must not be empty"},
+		},
+		{
+			name:     "Too many fields",
+			payload:  `{"blocks":[{"type":"section","fields":[` + strings.TrimSuffix(strings.Repeat(`{"type":"mrkdwn","text":"x"},`, 11), ",") + `]}]}`,
+			expected: []string{"blocks[0].fields: has 11 fields"},
+		},
+		{
+			name:     "Duplicate block IDs",
+			payload:  `{"blocks":[{"type":"divider","block_id":"a"},{"type":"divider","block_id":"a"}]}`,
+			expected: []string{`blocks[1].block_id: "a" is not unique`},
+		},
+		{
+			name:     "Too many blocks",
+			payload:  `{"blocks":[` + strings.TrimSuffix(strings.Repeat(`{"type":"divider"},`, 51), ",") + `]}`,
+			expected: []string{"blocks: has 51 blocks"},
+		},
+		{
+			name:     "Unknown block type",
+			payload:  `{"blocks":[{"type":"table"}]}`,
+			expected: []string{"blocks[0].type: unknown block type table"},
+		},
+		{
+			name:     "Invalid button",
+			payload:  `{"blocks":[{"type":"actions","elements":[{"type":"button","text":{"type":"plain_text","text":"Go"},"style":"secondary"}]}]}`,
+			expected: []string{"blocks[0].elements[0].style: is secondary"},
+		},
+		{
+			name:     "Image without alt text",
+			payload:  `{"blocks":[{"type":"image","image_url":"https://example.com/chart.png"}]}`,
+			expected: []string{"blocks[0].alt_text: is required"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problems := ValidateBlockKit([]byte(tt.payload))
+			if len(problems) != len(tt.expected) {
+				t.Fatalf("Expected %d problems, got %v", len(tt.expected), problems)
+			}
+			for i, problem := range problems {
+				if !strings.HasPrefix(problem.Error(), tt.expected[i]) {
+					t.Errorf("Expected %q, got %q", tt.expected[i], problem)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrChannelNotFound is returned when a channel cannot be resolved to a
+// Slack ID.
+var ErrChannelNotFound = errors.New("channel not found")
+
+// Channel is a conversation as reported by conversations.list and
+// conversations.info.
+type Channel struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	IsPrivate  bool   `json:"is_private"`
+	IsArchived bool   `json:"is_archived"`
+	IsMember   bool   `json:"is_member"`
+}
+
+// label returns the channel as shown in messages, #name or the ID.
+func (c Channel) label() string {
+	if c.Name == "" {
+		return c.ID
+	}
+	return "#" + c.Name
+}
+
+// ChannelResolver resolves channels given as #name, name or ID to their
+// Slack ID, and checks that the bot can post to them.
+type ChannelResolver struct {
+	// ListChannels returns a page of the workspace's channels and the
+	// cursor of the next page, which is empty on the last page.
+	ListChannels func(cursor string) ([]Channel, string, error)
+	// ChannelInfo returns the channel with the ID.
+	ChannelInfo func(id string) (Channel, error)
+	// Join adds the bot to the public channel with the ID.
+	Join func(id string) error
+	// AutoJoin makes the bot join public channels it is not a member of.
+	AutoJoin bool
+
+	// byName caches the channels listed so far by their lower case name;
+	// cursor is the next page to list and listed is set after the last.
+	byName map[string]*Channel
+	byID   map[string]*Channel
+	cursor string
+	listed bool
+}
+
+// Resolve returns the channel. It wraps ErrChannelNotFound when the channel
+// does not exist or is not visible to the bot, and fails for archived
+// channels and private channels the bot is not a member of.
+func (r *ChannelResolver) Resolve(channel string) (Channel, error) {
+	channel = strings.TrimSpace(channel)
+	if !ValidateSlackChannel(channel) {
+		return Channel{}, fmt.Errorf("invalid channel %q", channel)
+	}
+	var c *Channel
+	var err error
+	if isSlackID(channel, "CGD") {
+		c, err = r.byIDLookup(channel)
+	} else {
+		c, err = r.byNameLookup(strings.ToLower(strings.TrimPrefix(channel, "#")))
+	}
+	if err != nil {
+		return Channel{}, err
+	}
+
+	switch {
+	case c.IsArchived:
+		return Channel{}, fmt.Errorf("channel %s is archived", c.label())
+	case c.IsPrivate && !c.IsMember:
+		return Channel{}, fmt.Errorf("the bot is not a member of the private channel %s, invite it with /invite", c.label())
+	case !c.IsMember && r.AutoJoin:
+		if err := r.Join(c.ID); err != nil {
+			return Channel{}, fmt.Errorf("error while joining channel %s: %w", c.label(), err)
+		}
+		c.IsMember = true
+	}
+	return *c, nil
+}
+
+func (r *ChannelResolver) byIDLookup(id string) (*Channel, error) {
+	if c, ok := r.byID[id]; ok {
+		return c, nil
+	}
+	// Direct messages are not listed and need no checks.
+	if strings.HasPrefix(id, "D") {
+		return &Channel{ID: id, IsMember: true}, nil
+	}
+	c, err := r.ChannelInfo(id)
+	if IsSlackError(err, "channel_not_found") {
+		return nil, fmt.Errorf("%w: no channel with ID %s visible to the bot", ErrChannelNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.add(c), nil
+}
+
+// byNameLookup lists further pages until the channel is found.
+func (r *ChannelResolver) byNameLookup(name string) (*Channel, error) {
+	for {
+		if c, ok := r.byName[name]; ok {
+			return c, nil
+		}
+		if r.listed {
+			return nil, fmt.Errorf("%w: no channel #%s, private channels are only visible once the bot is invited", ErrChannelNotFound, name)
+		}
+		channels, cursor, err := r.ListChannels(r.cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range channels {
+			r.add(c)
+		}
+		r.cursor, r.listed = cursor, cursor == ""
+	}
+}
+
+func (r *ChannelResolver) add(c Channel) *Channel {
+	if r.byID == nil {
+		r.byName, r.byID = map[string]*Channel{}, map[string]*Channel{}
+	}
+	if cached, ok := r.byID[c.ID]; ok {
+		return cached
+	}
+	r.byID[c.ID] = &c
+	if c.Name != "" {
+		r.byName[strings.ToLower(c.Name)] = &c
+	}
+	return &c
+}
+
+// newChannelResolver returns the resolver using the Web API with the retry
+// policy of the inputs.
+func newChannelResolver(e *Environment) *ChannelResolver {
+	policy := newRetryPolicy(e.Slack.Retries)
+	return &ChannelResolver{
+		ListChannels: func(cursor string) (channels []Channel, next string, err error) {
+			err = policy.do("conversations.list", func() error {
+				channels, next, err = slackAPI.ListChannels(cursor)
+				return err
+			})
+			return channels, next, err
+		},
+		ChannelInfo: func(id string) (c Channel, err error) {
+			err = policy.do("conversations.info", func() error {
+				c, err = slackAPI.ChannelInfo(id)
+				return err
+			})
+			return c, err
+		},
+		Join: func(id string) error {
+			return policy.do("conversations.join", func() error { return slackAPI.JoinChannel(id) })
+		},
+		AutoJoin: e.Slack.AutoJoin,
+	}
+}
+
+// resolvesChannels reports whether the channels are looked up before use:
+// when asked to, and when a channel is given by name to a method that only
+// accepts IDs.
+func (e *Environment) resolvesChannels() bool {
+	if e.Slack.ResolveChannels || e.Slack.AutoJoin {
+		return true
+	}
+	if !e.needsChannelIDs() {
+		return false
+	}
+	for _, channel := range e.Channels() {
+		if !isSlackID(channel, "CGD") {
+			return true
+		}
+	}
+	return false
+}
+
+// needsChannelIDs reports whether the inputs operate on existing messages
+// or read the channel history, which Slack only allows by channel ID.
+func (e *Environment) needsChannelIDs() bool {
+	switch e.Operation {
+	case OperationReact, OperationCancel, OperationDelete:
+		return true
+	}
+	if e.Slack.MessageTS != "" || e.Digest.Key != "" {
+		return true
+	}
+	return e.Slack.StateBackend == StateBackendChannel && (e.Slack.MessageKey != "" || e.Dedupe.Window != "")
+}
+
+// resolveChannels replaces the channels of the inputs with their IDs and
+// remembers their names for the quiet hours.
+func resolveChannels(e *Environment, r *ChannelResolver) error {
+	var ids []string
+	for _, channel := range e.Channels() {
+		c, err := r.Resolve(channel)
+		if err != nil {
+			return err
+		}
+		if !c.IsMember {
+			logger.Noticef("the bot is not a member of %s, posting needs the chat:write.public scope or auto_join", c.label())
+		}
+		if e.ChannelNames == nil {
+			e.ChannelNames = map[string]string{}
+		}
+		e.ChannelNames[c.ID] = c.Name
+		ids = append(ids, c.ID)
+	}
+	e.Slack.Channel = strings.Join(ids, ",")
+	return nil
+}
+
+// ListChannels returns a page of public and private channels, archived
+// ones included, and the cursor of the next page.
+func (w *webAPI) ListChannels(cursor string) ([]Channel, string, error) {
+	var response struct {
+		Channels         []Channel `json:"channels"`
+		ResponseMetadata struct {
+			NextCursor string `json:"next_cursor"`
+		} `json:"response_metadata"`
+	}
+	values := url.Values{
+		"types":            {"public_channel,private_channel"},
+		"exclude_archived": {"false"},
+		"limit":            {"1000"},
+	}
+	if cursor != "" {
+		values.Set("cursor", cursor)
+	}
+	if err := w.call("conversations.list", values, &response); err != nil {
+		return nil, "", err
+	}
+	return response.Channels, response.ResponseMetadata.NextCursor, nil
+}
+
+// ChannelInfo returns the channel with the ID.
+func (w *webAPI) ChannelInfo(id string) (Channel, error) {
+	var response struct {
+		Channel Channel `json:"channel"`
+	}
+	err := w.call("conversations.info", url.Values{"channel": {id}}, &response)
+	return response.Channel, err
+}
+
+// JoinChannel adds the bot to the public channel with the ID.
+func (w *webAPI) JoinChannel(id string) error {
+	return w.call("conversations.join", url.Values{"channel": {id}}, nil)
+}
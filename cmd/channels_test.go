@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChannelResolver(t *testing.T) {
+	pages := [][]Channel{
+		{
+			{ID: "C0GENERAL", Name: "general", IsMember: true},
+			{ID: "C0OLD", Name: "old-releases", IsArchived: true},
+		},
+		{
+			{ID: "C0OPS", Name: "ops"},
+			{ID: "G0SECRET", Name: "secret", IsPrivate: true},
+		},
+	}
+	var listed []string
+	var joined []string
+	resolver := &ChannelResolver{
+		ListChannels: func(cursor string) ([]Channel, string, error) {
+			listed = append(listed, cursor)
+			if cursor == "" {
+				return pages[0], "page-2", nil
+			}
+			return pages[1], "", nil
+		},
+		ChannelInfo: func(id string) (Channel, error) {
+			if id == "C0123ABC" {
+				return Channel{ID: id, Name: "releases", IsMember: true}, nil
+			}
+			return Channel{}, &SlackError{Method: "conversations.info", Code: "channel_not_found"}
+		},
+		Join: func(id string) error {
+			joined = append(joined, id)
+			return nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		channel  string
+		expected string
+		err      string
+	}{
+		{"Name", "general", "C0GENERAL", ""},
+		{"Hash and upper case", "#General", "C0GENERAL", ""},
+		{"Name in upper case", "GENERAL", "C0GENERAL", ""},
+		{"ID", "C0123ABC", "C0123ABC", ""},
+		{"Direct message", "D0123ABC", "D0123ABC", ""},
+		{"Second page", "ops", "C0OPS", ""},
+		{"Archived", "old-releases", "", "channel #old-releases is archived"},
+		{"Private without membership", "secret", "", "not a member of the private channel #secret"},
+		{"Unknown name", "nowhere", "", "no channel #nowhere"},
+		{"Unknown ID", "C0MISSING", "", "no channel with ID C0MISSING"},
+		{"Invalid", "team updates", "", "invalid channel"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := resolver.Resolve(test.channel)
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("Expected an error containing %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if c.ID != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, c.ID)
+			}
+		})
+	}
+
+	if _, err := resolver.Resolve("nowhere"); !errors.Is(err, ErrChannelNotFound) {
+		t.Errorf("Expected ErrChannelNotFound, got %v", err)
+	}
+	if len(listed) != 2 || listed[0] != "" || listed[1] != "page-2" {
+		t.Errorf("Expected each page to be listed once, got %q", listed)
+	}
+	if len(joined) != 0 {
+		t.Errorf("Expected no channel to be joined, got %q", joined)
+	}
+
+	resolver.AutoJoin = true
+	c, err := resolver.Resolve("#ops")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !c.IsMember || len(joined) != 1 || joined[0] != "C0OPS" {
+		t.Errorf("Expected C0OPS to be joined, got %q", joined)
+	}
+}
+
+func TestPostResolvesChannels(t *testing.T) {
+	message := map[string]string{
+		"INPUT_TITLE":            "Deploy",
+		"INPUT_TEXT":             "Deploying to production",
+		"INPUT_RESOLVE_CHANNELS": "true",
+	}
+	newFake := func(t *testing.T) *fakeSlack {
+		fake := newFakeSlack(t)
+		fake.pageSize = 1
+		fake.addChannel("C0OPS", "ops")
+		fake.addChannel("C0OLD", "old")
+		fake.channels[1]["is_member"] = false
+		fake.channels[2]["is_archived"] = true
+		return fake
+	}
+
+	t.Run("Name on a later page", func(t *testing.T) {
+		fake := newFake(t)
+		t.Setenv("INPUT_SLACK_CHANNEL", "#ops")
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if calls := len(fake.requestsFor("conversations.list")); calls != 2 {
+			t.Errorf("Expected 2 calls of conversations.list, got %d", calls)
+		}
+		if requests := fake.requestsFor("chat.postMessage"); len(requests) != 1 || requests[0].param("channel") != "C0OPS" {
+			t.Errorf("Expected the message to be posted to C0OPS, got %+v", requests)
+		}
+		if calls := len(fake.requestsFor("conversations.join")); calls != 0 {
+			t.Errorf("Expected no call of conversations.join, got %d", calls)
+		}
+		if !strings.Contains(outputs, "channel=C0OPS\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Auto join", func(t *testing.T) {
+		fake := newFake(t)
+		t.Setenv("INPUT_SLACK_CHANNEL", "general,ops")
+		t.Setenv("INPUT_AUTO_JOIN", "true")
+		if _, err := runWithFakeSlack(t, fake, message); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		requests := fake.requestsFor("conversations.join")
+		if len(requests) != 1 || requests[0].param("channel") != "C0OPS" {
+			t.Errorf("Expected C0OPS to be joined, got %+v", requests)
+		}
+		if messages := fake.channelMessages("C0OPS"); len(messages) != 1 {
+			t.Errorf("Expected 1 message in ops, got %d", len(messages))
+		}
+	})
+
+	t.Run("Archived", func(t *testing.T) {
+		fake := newFake(t)
+		t.Setenv("INPUT_SLACK_CHANNEL", "general,old")
+		_, err := runWithFakeSlack(t, fake, message)
+		if err == nil || !strings.Contains(err.Error(), "channel #old is archived") {
+			t.Fatalf("Expected an error about the archived channel, got %v", err)
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 0 {
+			t.Errorf("Expected nothing to be posted, got %d calls", calls)
+		}
+	})
+
+	t.Run("Names for methods taking IDs", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		fake.addChannel("C0OPS", "ops")
+		outputs, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_TITLE":         "Deploy",
+			"INPUT_TEXT":          "Deploying to production",
+			"INPUT_SLACK_CHANNEL": "#ops",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !strings.Contains(outputs, "ts=1700000000.000001\n") {
+			t.Fatalf("Unexpected outputs %q", outputs)
+		}
+
+		if _, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_OPERATION":     OperationReact,
+			"INPUT_SLACK_CHANNEL": "#ops",
+			"INPUT_MESSAGE_TS":    "1700000000.000001",
+			"INPUT_REACTIONS":     "rocket",
+		}); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if requests := fake.requestsFor("reactions.add"); len(requests) != 1 || requests[0].param("channel") != "C0OPS" {
+			t.Errorf("Expected the reaction to be added in C0OPS, got %+v", requests)
+		}
+
+		if _, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_OPERATION":     OperationDelete,
+			"INPUT_SLACK_CHANNEL": "ops",
+			"INPUT_MESSAGE_TS":    "1700000000.000001",
+		}); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if messages := fake.channelMessages("C0OPS"); len(messages) != 0 {
+			t.Errorf("Expected the message to be deleted, got %+v", messages)
+		}
+	})
+}
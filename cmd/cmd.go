@@ -1,7 +1,11 @@
 package main
 
 import (
-	"log"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	env "github.com/pal-paul/go-libraries/pkg/env"
 	slack "github.com/pal-paul/go-libraries/pkg/slack"
@@ -9,43 +13,685 @@ import (
 
 type Environment struct {
 	Input struct {
-		Title string `env:"INPUT_TITLE,required=true"`
-		Text  string `env:"INPUT_TEXT,required=true"`
+		Title   string  `env:"INPUT_TITLE"`
+		Text    string  `env:"INPUT_TEXT"`
+		Status  string  `env:"INPUT_STATUS"`
+		Fields  Fields  `env:"INPUT_FIELDS"`
+		Buttons Buttons `env:"INPUT_BUTTONS"`
+		DryRun  bool    `env:"INPUT_DRY_RUN"`
+		// Reactions are added to, and RemoveReactions removed from, the
+		// message identified by Slack.MessageTS.
+		Reactions       stringList `env:"INPUT_REACTIONS"`
+		RemoveReactions stringList `env:"INPUT_REMOVE_REACTIONS"`
+		// PostAt schedules the message instead of posting it right away;
+		// ScheduledAt is the parsed time.
+		PostAt      string `env:"INPUT_POST_AT"`
+		ScheduledAt time.Time
+		// MetadataEventType and MetadataPayload, a JSON object, set the
+		// message metadata; Metadata is the result merged with the GitHub
+		// context.
+		MetadataEventType string `env:"INPUT_METADATA_EVENT_TYPE"`
+		MetadataPayload   string `env:"INPUT_METADATA_PAYLOAD"`
+		Metadata          *MessageMetadata
+		// Priority decides whether quiet hours hold the message back.
+		Priority string `env:"INPUT_PRIORITY"`
+		// Mentions is the resolved mention line.
+		Mentions string
+	}
+	Mention MentionRules
+	Config  struct {
+		File    string `env:"INPUT_CONFIG_FILE"`
+		Profile string `env:"INPUT_PROFILE"`
+		Routes  Routes `env:"INPUT_ROUTES"`
+		// QuietHours replaces the quiet_hours of the configuration file.
+		QuietHours QuietHoursList `env:"INPUT_QUIET_HOURS"`
+		// Redact are the redact patterns of the configuration file.
+		Redact []string
+		// UserMapping is the file mapping GitHub users and teams to Slack.
+		UserMapping string `env:"INPUT_USER_MAPPING_FILE"`
 	}
 	Slack struct {
-		Token   string `env:"INPUT_SLACK_TOKEN,required=true"`
-		Channel string `env:"INPUT_SLACK_CHANNEL,required=true"`
+		Token   string `env:"INPUT_SLACK_TOKEN"`
+		Channel string `env:"INPUT_SLACK_CHANNEL"`
+		// MessageTS identifies an existing message to operate on.
+		MessageTS string `env:"INPUT_MESSAGE_TS"`
+		// ScheduledMessageID identifies the scheduled message to cancel.
+		ScheduledMessageID string `env:"INPUT_SCHEDULED_MESSAGE_ID"`
+		// EphemeralUser, a Slack ID, email or GitHub login, is the only
+		// user shown the message.
+		EphemeralUser string `env:"INPUT_EPHEMERAL_USER"`
+		// MessageKey names the posted message in the state store. Later
+		// runs with the same key update the message instead of posting a
+		// new one, and delete can find it by key instead of by ts.
+		MessageKey string `env:"INPUT_MESSAGE_KEY"`
+		// StateBackend selects where keys are recorded: StateFile,
+		// StateDir or the channel itself.
+		StateBackend string `env:"INPUT_STATE_BACKEND"`
+		StateFile    string `env:"INPUT_STATE_FILE"`
+		StateDir     string `env:"INPUT_STATE_DIR"`
+		// Idempotent makes deleting a message that is already gone succeed.
+		Idempotent bool `env:"INPUT_IDEMPOTENT"`
+		// Retries is how often a send that failed with a transient error
+		// is repeated.
+		Retries int `env:"INPUT_RETRIES"`
+		// ResolveChannels looks up the IDs of the channels before sending
+		// and checks that the bot can post to them. AutoJoin, which
+		// implies it, makes the bot join public channels it is not a
+		// member of. Neither is on by default, so channels are only
+		// checked when asked to.
+		ResolveChannels bool `env:"INPUT_RESOLVE_CHANNELS"`
+		AutoJoin        bool `env:"INPUT_AUTO_JOIN"`
+	}
+	// ChannelNames maps the IDs of the resolved channels to their names.
+	ChannelNames map[string]string
+	// Fallback sends the message by email when Slack cannot be reached
+	// after the retries.
+	Fallback struct {
+		EmailTo      stringList `env:"INPUT_FALLBACK_EMAIL_TO"`
+		SMTPHost     string     `env:"INPUT_SMTP_HOST"`
+		SMTPPort     int        `env:"INPUT_SMTP_PORT"`
+		SMTPUsername string     `env:"INPUT_SMTP_USERNAME"`
+		SMTPPassword string     `env:"INPUT_SMTP_PASSWORD"`
+		SMTPFrom     string     `env:"INPUT_SMTP_FROM"`
+	}
+	// Digest collects the results of several jobs in one message under
+	// Key; every invocation adds the entry of Job.
+	Digest struct {
+		Key      string `env:"INPUT_DIGEST_KEY"`
+		Job      string `env:"INPUT_DIGEST_JOB"`
+		Duration string `env:"INPUT_DURATION"`
+		// Total is the number of jobs expected, if known.
+		Total int `env:"INPUT_DIGEST_TOTAL"`
+	}
+	// Dedupe suppresses a message that was already posted within Window,
+	// a Go duration; Period is the parsed Window. Key identifies the
+	// message instead of its rendered content. Reply notes the repeat in
+	// the thread of the first message.
+	Dedupe struct {
+		Key    string `env:"INPUT_DEDUPE_KEY"`
+		Window string `env:"INPUT_DEDUPE_WINDOW"`
+		Reply  bool   `env:"INPUT_DEDUPE_REPLY"`
+		Period time.Duration
+	}
+	// Provider selects the chat service; everything but Slack is reached
+	// through WebhookURL.
+	Provider struct {
+		Name       string `env:"INPUT_PROVIDER"`
+		WebhookURL string `env:"INPUT_WEBHOOK_URL"`
 	}
+	// Log configures the log lines: the lowest level shown and, outside
+	// GitHub Actions, the text or JSON format.
+	Log struct {
+		Level  string `env:"INPUT_LOG_LEVEL"`
+		Format string `env:"INPUT_LOG_FORMAT"`
+	}
+	// Operation selects what the action does; it defaults to posting.
+	Operation string `env:"INPUT_OPERATION"`
+	GitHub    GitHubContext
+	// Route names the routing rule that matched. Skip is set when routing
+	// rules are configured and none of them matched.
+	Route string
+	Skip  bool
 }
 
+// commandRender is the subcommand that renders the message without sending
+// it. It is equivalent to running with INPUT_DRY_RUN=true.
+const commandRender = "render"
+
+const (
+	OperationPost   = "post"
+	OperationReact  = "react"
+	OperationCancel = "cancel"
+	OperationDelete = "delete"
+)
+
 var (
-	envVar      Environment
-	slackClient slack.ISlack
+	envVar Environment
+	// slackAPI makes every Slack call of the action.
+	slackAPI slackService
+	mailer   smtpMailer
+	// timeNow is the clock of the action; tests replace it.
+	timeNow = time.Now
 )
 
+// envSlackAPIURL overrides the Slack Web API base URL, for example to run
+// the action against a fake server.
+const envSlackAPIURL = "SLACK_API_URL"
+
+// appOptions override how initializeApp sets up the Slack clients.
+type appOptions struct {
+	client  slackService
+	apiURL  string
+	smtpTLS *tls.Config
+}
+
+type appOption func(*appOptions)
+
+// withSlackClient makes every Slack call through client instead of the
+// Web API.
+func withSlackClient(client slackService) appOption {
+	return func(o *appOptions) {
+		o.client = client
+	}
+}
+
+// withSlackAPIURL sends every Slack call to the Web API at apiURL.
+func withSlackAPIURL(apiURL string) appOption {
+	return func(o *appOptions) {
+		o.apiURL = strings.TrimSuffix(apiURL, "/")
+	}
+}
+
+// withSMTPTLSConfig sets the TLS configuration of the fallback email
+// connection, for example to trust the certificate of a test server.
+func withSMTPTLSConfig(config *tls.Config) appOption {
+	return func(o *appOptions) {
+		o.smtpTLS = config
+	}
+}
+
 // Initialize environment variables and Slack client
-func initializeApp() error {
+func initializeApp(opts ...appOption) error {
+	var options appOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	envVar = Environment{}
 	_, err := env.Unmarshal(&envVar)
 	if err != nil {
 		return err
 	}
+	if err := configureLogger(envVar.Log.Level, envVar.Log.Format); err != nil {
+		return err
+	}
+	secrets := secretValues(&envVar)
+	logger.Mask(secrets...)
+	if err := applyConfigFile(&envVar); err != nil {
+		return err
+	}
+	if envVar.Skip {
+		return nil
+	}
+	if err := envVar.validate(); err != nil {
+		return err
+	}
+	if redactor, err = NewRedactor(secrets, envVar.Config.Redact); err != nil {
+		return err
+	}
+	envVar.Input.Metadata, err = BuildMetadata(&envVar, envVar.Input.MetadataEventType, envVar.Input.MetadataPayload)
+	if err != nil {
+		return err
+	}
+	if envVar.Input.PostAt != "" {
+		if envVar.Input.ScheduledAt, err = ParsePostAt(envVar.Input.PostAt, timeNow()); err != nil {
+			return err
+		}
+	}
+	if envVar.Dedupe.Window != "" {
+		envVar.Dedupe.Period, err = time.ParseDuration(envVar.Dedupe.Window)
+		if err != nil || envVar.Dedupe.Period <= 0 {
+			return fmt.Errorf("invalid INPUT_DEDUPE_WINDOW %q, expected a duration such as 30m or 1h", envVar.Dedupe.Window)
+		}
+	}
 
-	slackClient = slack.New(
-		slack.WithToken(envVar.Slack.Token),
-	)
+	api := newWebAPI(envVar.Slack.Token)
+	if options.apiURL != "" {
+		api.baseURL = options.apiURL
+	}
+	slackAPI = api
+	if options.client != nil {
+		slackAPI = options.client
+	}
+	mailer = newSMTPMailer(&envVar)
+	mailer.TLSConfig = options.smtpTLS
 	return nil
 }
 
+// validate checks the inputs that are required by the operation once the
+// configuration file has been applied.
+func (e *Environment) validate() error {
+	if e.Provider.Name != "" && e.Provider.Name != ProviderSlack {
+		return e.validateProvider()
+	}
+
+	var required []requiredInput
+	switch e.Operation {
+	case "", OperationPost:
+		switch {
+		case e.Digest.Key != "":
+			required = append(required,
+				requiredInput{"INPUT_TITLE", e.Input.Title},
+				requiredInput{"INPUT_STATUS", e.Input.Status},
+			)
+		case !e.reactionOnly():
+			required = append(required,
+				requiredInput{"INPUT_TITLE", e.Input.Title},
+				requiredInput{"INPUT_TEXT", e.Input.Text},
+			)
+		}
+	case OperationReact:
+		required = append(required, requiredInput{"INPUT_MESSAGE_TS", e.Slack.MessageTS})
+	case OperationCancel:
+		required = append(required, requiredInput{"INPUT_SCHEDULED_MESSAGE_ID", e.Slack.ScheduledMessageID})
+	case OperationDelete:
+		if e.Slack.MessageKey == "" {
+			required = append(required, requiredInput{"INPUT_MESSAGE_TS", e.Slack.MessageTS})
+		}
+	default:
+		return fmt.Errorf("invalid operation %q, expected %s, %s, %s or %s", e.Operation, OperationPost, OperationReact, OperationCancel, OperationDelete)
+	}
+	// The token is only needed when Slack is actually called.
+	if !e.Input.DryRun {
+		required = append(required, requiredInput{"INPUT_SLACK_TOKEN", e.Slack.Token})
+	}
+	// A recorded key carries its own channel; the channel backend has to
+	// know where to search.
+	if e.Operation != OperationDelete || e.Slack.MessageKey == "" || e.Slack.StateBackend == StateBackendChannel {
+		required = append(required, requiredInput{"INPUT_SLACK_CHANNEL", e.Slack.Channel})
+	}
+
+	for _, r := range required {
+		if r.value == "" {
+			return &env.ErrMissingRequiredValue{Value: r.name}
+		}
+	}
+	if e.Slack.EphemeralUser != "" && e.Input.PostAt != "" {
+		return fmt.Errorf("ephemeral messages cannot be scheduled")
+	}
+	if e.Slack.MessageKey != "" {
+		if len(e.Channels()) > 1 {
+			return fmt.Errorf("INPUT_MESSAGE_KEY needs a single channel, got %s", e.Slack.Channel)
+		}
+		if e.Input.PostAt != "" || e.Slack.EphemeralUser != "" {
+			return fmt.Errorf("INPUT_MESSAGE_KEY cannot be used with scheduled or ephemeral messages")
+		}
+	}
+	if e.Digest.Key != "" {
+		if len(e.Channels()) > 1 {
+			return fmt.Errorf("INPUT_DIGEST_KEY needs a single channel, got %s", e.Slack.Channel)
+		}
+		if e.Slack.MessageKey != "" || e.Slack.MessageTS != "" || e.Input.PostAt != "" || e.Slack.EphemeralUser != "" {
+			return fmt.Errorf("INPUT_DIGEST_KEY cannot be combined with message keys, message_ts, scheduled or ephemeral messages")
+		}
+		if e.Digest.Job == "" && e.GitHub.Job == "" {
+			return &env.ErrMissingRequiredValue{Value: "INPUT_DIGEST_JOB"}
+		}
+	}
+	if e.Dedupe.Key != "" && e.Dedupe.Window == "" {
+		return &env.ErrMissingRequiredValue{Value: "INPUT_DEDUPE_WINDOW"}
+	}
+	if e.Dedupe.Window != "" && (e.Slack.MessageKey != "" || e.Input.PostAt != "" || e.Slack.EphemeralUser != "" || e.Digest.Key != "") {
+		return fmt.Errorf("INPUT_DEDUPE_WINDOW cannot be combined with message keys, digests, scheduled or ephemeral messages")
+	}
+	if _, ok := priorities[e.Input.Priority]; e.Input.Priority != "" && !ok {
+		return fmt.Errorf("invalid INPUT_PRIORITY %q, expected %s", e.Input.Priority, priorityNames())
+	}
+	for i := range e.Config.QuietHours {
+		if err := e.Config.QuietHours[i].validate(); err != nil {
+			return err
+		}
+	}
+	if e.Slack.MessageTS != "" && len(e.Channels()) > 1 {
+		return fmt.Errorf("INPUT_MESSAGE_TS needs a single channel, got %s", e.Slack.Channel)
+	}
+	if e.Slack.Retries < 0 {
+		return fmt.Errorf("invalid INPUT_RETRIES %d, expected 0 or more", e.Slack.Retries)
+	}
+	if len(e.Fallback.EmailTo) > 0 {
+		for _, r := range []requiredInput{{"INPUT_SMTP_HOST", e.Fallback.SMTPHost}, {"INPUT_SMTP_FROM", e.Fallback.SMTPFrom}} {
+			if r.value == "" {
+				return &env.ErrMissingRequiredValue{Value: r.name}
+			}
+		}
+		for _, address := range append([]string{e.Fallback.SMTPFrom}, e.Fallback.EmailTo...) {
+			if strings.ContainsAny(address, "\r\n") {
+				return fmt.Errorf("invalid email address %q in INPUT_SMTP_FROM or INPUT_FALLBACK_EMAIL_TO", address)
+			}
+		}
+	}
+	return e.Mention.validate()
+}
+
+type requiredInput struct {
+	name  string
+	value string
+}
+
+// reactionOnly reports whether a post only marks the message identified by
+// INPUT_MESSAGE_TS with the status reaction, without posting a new message.
+func (e *Environment) reactionOnly() bool {
+	return e.Slack.MessageTS != "" && e.Input.Status != "" && e.Input.Title == "" && e.Input.Text == ""
+}
+
+// Channels returns the target channels; INPUT_SLACK_CHANNEL may hold a
+// comma separated list.
+func (e *Environment) Channels() []string {
+	var channels []string
+	for _, channel := range strings.Split(e.Slack.Channel, ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			channels = append(channels, channel)
+		}
+	}
+	return channels
+}
+
 func main() {
-	if err := initializeApp(); err != nil {
-		log.Fatal(err)
+	var opts []appOption
+	if apiURL := os.Getenv(envSlackAPIURL); apiURL != "" {
+		opts = append(opts, withSlackAPIURL(apiURL))
+	}
+	if err := execute(os.Args[1:], opts...); err != nil {
+		logger.Fatal(err)
 	}
+}
 
-	message := SlackMessageBuilder(envVar.Input.Title, envVar.Input.Text, envVar.Slack.Channel)
-	_, err := slackClient.AddFormattedMessage(envVar.Slack.Channel, message)
-	if err != nil {
-		log.Fatalf("error while sending message to slack: %v", err)
+// execute runs the subcommand in args, or the action when there is none.
+func execute(args []string, opts ...appOption) error {
+	command := ""
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case commandServe:
+		return serve(args[1:])
+	case commandRender:
+		os.Setenv("INPUT_DRY_RUN", "true")
+	}
+	validateOnly := command == commandValidate
+	if err := initializeApp(opts...); err != nil {
+		return err
+	}
+	if validateOnly || !envVar.Input.DryRun {
+		if err := preflight(); err != nil {
+			// While Slack is unreachable the token cannot be checked,
+			// but the message can still go out by email.
+			if validateOnly || !envVar.fallsBackToEmail(err) {
+				return err
+			}
+			logger.Warnf("could not check the slack token, sending anyway: %v", err)
+		}
+	}
+	if validateOnly {
+		logger.Infof("inputs, token and scopes are valid")
+		return nil
 	}
+	return run()
+}
+
+// run performs the requested operation.
+func run() error {
+	if envVar.Skip {
+		logger.Noticef("no route matched, nothing to send")
+		return nil
+	}
+	if envVar.Route != "" {
+		logger.Infof("route %s matched", envVar.Route)
+	}
+
+	if provider := envVar.webhookProvider(); provider != nil {
+		return sendToProvider(provider)
+	}
+	// Channels are resolved first: the methods operating on existing
+	// messages only accept IDs, and an archived channel or one the bot
+	// cannot post to fails before anything is sent.
+	if envVar.resolvesChannels() && !envVar.Input.DryRun {
+		if err := resolveChannels(&envVar, newChannelResolver(&envVar)); err != nil {
+			return fmt.Errorf("error while resolving channels: %w", err)
+		}
+	}
+	switch envVar.Operation {
+	case OperationReact:
+		return react()
+	case OperationCancel:
+		return cancel()
+	case OperationDelete:
+		return deleteFromEnv()
+	}
+	if envVar.Digest.Key != "" {
+		return digest()
+	}
+	return post()
+}
+
+// post sends, or schedules, the message to every channel. When a status and
+// the ts of an earlier message are given, that message gets the status
+// reaction as well.
+func post() error {
+	if err := resolveMentions(&envVar); err != nil {
+		return fmt.Errorf("error while resolving mentions: %v", err)
+	}
+
+	var ephemeralUser string
+	if envVar.Slack.EphemeralUser != "" && !envVar.Input.DryRun {
+		users, err := newUserResolver(&envVar)
+		if err != nil {
+			return err
+		}
+		if ephemeralUser, err = users.Resolve(envVar.Slack.EphemeralUser); err != nil {
+			return fmt.Errorf("error while resolving ephemeral user: %w", err)
+		}
+	}
+
+	var poster messagePoster = slackAPI
+	var updater messageUpdater = slackAPI
+	metadata := envVar.Input.Metadata
+	if envVar.Slack.MessageKey != "" {
+		metadata = withMessageKey(metadata, envVar.Slack.MessageKey)
+	}
+	if metadata != nil {
+		client := metadataClient{api: slackAPI, metadata: metadata}
+		poster, updater = client, client
+	}
+	policy := newRetryPolicy(envVar.Slack.Retries)
+	client := retryClient{poster: poster, updater: updater, policy: policy}
+
+	if !envVar.reactionOnly() {
+		if err := sendMessages(client, client, policy, ephemeralUser); err != nil {
+			channels := envVar.Channels()
+			n := NotificationFromSlack(buildMessage(channels[0]), envVar.Input.Status)
+			return emailFallback(err, n, mailer)
+		}
+	}
+
+	if envVar.Slack.MessageTS != "" && envVar.Input.Status != "" {
+		return react()
+	}
+	return nil
+}
+
+// sendMessages sends, or schedules, the message to every channel and sets
+// the outputs.
+func sendMessages(poster messagePoster, updater messageUpdater, policy retryPolicy, ephemeralUser string) (err error) {
+	var channels, timestamps, scheduled, quiet []string
+	defer func() {
+		if err != nil && len(channels) > 0 {
+			err = &PartialDeliveryError{Channels: channels, Err: err}
+		}
+	}()
+	// sent are the messages posted, and message the first of them, for
+	// the job summary.
+	var sent []slack.MessageRef
+	var sentMessage slack.Message
+	posted := func(ref slack.MessageRef, message slack.Message) {
+		if len(sent) == 0 {
+			sentMessage = message
+		}
+		sent = append(sent, ref)
+		channels, timestamps = append(channels, ref.Channel), append(timestamps, ref.Timestamp)
+	}
+	suppressed := 0
+	redactions := -1
+	for _, channel := range envVar.Channels() {
+		before := redactor.Count()
+		built := buildMessage(channel)
+		message := built
+		if redactions < 0 {
+			// Every channel gets the same text; count it once.
+			redactions = redactor.Count() - before
+		}
+		scheduledAt := envVar.Input.ScheduledAt
+		action := "none"
+		if ephemeralUser == "" && scheduledAt.IsZero() {
+			if window, end, ok := envVar.quietHours(channel, timeNow()); ok {
+				action = window.Action
+				if action == QuietSchedule && (envVar.Slack.MessageKey != "" || envVar.Dedupe.Period > 0) {
+					// Keyed and deduplicated messages cannot be scheduled:
+					// later runs would not find the scheduled message, and
+					// every repeat would be scheduled again.
+					action = QuietSilent
+				}
+				logger.Noticef("quiet hours in %s until %s, %s priority message: %s", channel, end.Format(time.RFC3339), envVar.priority(), action)
+				switch action {
+				case QuietSchedule:
+					scheduledAt = end
+				case QuietSilent:
+					message = silenced(message)
+				}
+			}
+		}
+		quiet = append(quiet, action)
+		if action == QuietDrop {
+			continue
+		}
+		if envVar.Input.DryRun {
+			if err := renderMessage(os.Stdout, message, envVar.Input.Metadata); err != nil {
+				return fmt.Errorf("error while rendering message: %v", err)
+			}
+			if !scheduledAt.IsZero() {
+				logger.Infof("dry run: would schedule for %s", scheduledAt.Format(time.RFC3339))
+			}
+			continue
+		}
+		if ephemeralUser != "" {
+			err := policy.do("chat.postEphemeral", func() error {
+				return slackAPI.PostEphemeral(channel, ephemeralUser, message)
+			})
+			if err != nil {
+				return ephemeralError(err, channel, envVar.Slack.EphemeralUser)
+			}
+			continue
+		}
+		if !scheduledAt.IsZero() {
+			var id string
+			err := policy.do("chat.scheduleMessage", func() error {
+				var err error
+				id, err = slackAPI.ScheduleMessage(channel, message, envVar.Input.Metadata, scheduledAt)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("error while scheduling message: %w", err)
+			}
+			logger.Infof("message to %s scheduled for %s as %s", channel, scheduledAt.Format(time.RFC3339), id)
+			channels, scheduled = append(channels, channel), append(scheduled, id)
+			continue
+		}
+		if envVar.Slack.MessageKey != "" {
+			store, err := newMessageStore(&envVar)
+			if err != nil {
+				return err
+			}
+			ref, updated, err := publish(poster, updater, store, envVar.Slack.MessageKey, channel, message)
+			if err != nil {
+				return err
+			}
+			if updated {
+				logger.Infof("updated message %s in %s", envVar.Slack.MessageKey, ref.Channel)
+			}
+			posted(ref, message)
+			continue
+		}
+		if envVar.Dedupe.Period > 0 {
+			store, err := newDedupeStore(&envVar, channel)
+			if err != nil {
+				return err
+			}
+			// The key is taken before silencing, so that repeats match
+			// inside and outside quiet hours.
+			key, err := dedupeKey(channel, envVar.Dedupe.Key, built)
+			if err != nil {
+				return err
+			}
+			ref, skipped, err := dedupe(poster, store, key, channel, message, envVar.Dedupe.Period, envVar.Dedupe.Reply)
+			if err != nil {
+				return err
+			}
+			if skipped {
+				// The message already posted keeps the ts outputs, but
+				// it was not sent by this run.
+				suppressed++
+				channels, timestamps = append(channels, ref.Channel), append(timestamps, ref.Timestamp)
+				continue
+			}
+			posted(ref, message)
+			continue
+		}
+		ref, err := poster.AddFormattedMessage(channel, message)
+		if err != nil {
+			return fmt.Errorf("error while sending message to slack: %w", err)
+		}
+		posted(ref, message)
+	}
+	if err := setPostOutputs(channels, timestamps, scheduled); err != nil {
+		return err
+	}
+	if len(sent) > 0 {
+		if err := reportSent(sentMessage, sent, policy); err != nil {
+			return err
+		}
+	}
+	if err := reportRedactions(max(redactions, 0)); err != nil {
+		return err
+	}
+	if len(envVar.Config.QuietHours) > 0 {
+		if err := setOutput("quiet_hours", strings.Join(quiet, ",")); err != nil {
+			return err
+		}
+	}
+	if envVar.Input.DryRun || len(channels) == 0 {
+		return nil
+	}
+	if envVar.Dedupe.Period > 0 {
+		// The message counts as suppressed when no channel got it.
+		if err := setOutput("suppressed", fmt.Sprint(suppressed == len(channels))); err != nil {
+			return err
+		}
+		if suppressed == len(channels) {
+			return nil
+		}
+	}
+	return setOutput("delivered_via", DeliveredViaSlack)
+}
+
+// setPostOutputs sets the channel, ts and scheduled_message_id outputs.
+// With several channels the values are comma separated in channel order.
+func setPostOutputs(channels []string, timestamps []string, scheduled []string) error {
+	outputs := []struct {
+		name   string
+		values []string
+	}{
+		{"channel", channels},
+		{"ts", timestamps},
+		{"scheduled_message_id", scheduled},
+	}
+	for _, output := range outputs {
+		if len(output.values) == 0 {
+			continue
+		}
+		if err := setOutput(output.name, strings.Join(output.values, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildMessage builds the message for channel from the parsed environment.
+func buildMessage(channel string) slack.Message {
+	text := withMentionLine(envVar.Input.Text, envVar.Input.Mentions)
+	message := SlackMessageBuilder(envVar.Input.Title, text, channel)
+	message.Blocks = append(message.Blocks, FieldsBlocks(envVar.Input.Fields)...)
+	message.Blocks = append(message.Blocks, ButtonsBlocks(envVar.Input.Buttons)...)
+	return message
 }
 
 func SlackMessageBuilder(title string, text string, channel string) slack.Message {
@@ -53,6 +699,8 @@ func SlackMessageBuilder(title string, text string, channel string) slack.Messag
 		Channel: channel,
 	}
 
+	// Secrets are masked before they can end up in Slack.
+	title, text = redactor.Redact(title), redactor.Redact(text)
 	message.Blocks = append(message.Blocks, slack.Block{
 		Type: slack.HeaderBlock,
 		Text: &slack.Text{
@@ -69,3 +717,45 @@ func SlackMessageBuilder(title string, text string, channel string) slack.Messag
 	})
 	return message
 }
+
+// maxSectionFields is the number of fields Slack accepts in a section block.
+const maxSectionFields = 10
+
+// FieldsBlocks renders fields as two column section blocks.
+func FieldsBlocks(fields Fields) []slack.Block {
+	var blocks []slack.Block
+	for i, field := range fields {
+		if i%maxSectionFields == 0 {
+			blocks = append(blocks, slack.Block{Type: slack.SectionBlock})
+		}
+		block := &blocks[len(blocks)-1]
+		block.Fields = append(block.Fields, slack.Field{
+			Type: slack.Mrkdwn,
+			Text: fmt.Sprintf("*%s*\n%s", redactor.Redact(field.Title), redactor.Redact(field.Value)),
+		})
+	}
+	return blocks
+}
+
+// buttonSeparator separates the links rendered by ButtonsBlocks.
+const buttonSeparator = "  •  "
+
+// ButtonsBlocks renders buttons as a row of links. Link buttons need a url
+// property that slack.Element does not carry, so they are rendered as mrkdwn
+// links instead.
+func ButtonsBlocks(buttons Buttons) []slack.Block {
+	if len(buttons) == 0 {
+		return nil
+	}
+	links := make([]string, 0, len(buttons))
+	for _, button := range buttons {
+		links = append(links, fmt.Sprintf("<%s|%s>", redactor.Redact(button.URL), redactor.Redact(button.Text)))
+	}
+	return []slack.Block{{
+		Type: slack.SectionBlock,
+		Text: &slack.Text{
+			Type: slack.Mrkdwn,
+			Text: strings.Join(links, buttonSeparator),
+		},
+	}}
+}
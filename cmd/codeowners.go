@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// codeOwnersPaths are the locations GitHub reads a CODEOWNERS file from, in
+// order of precedence.
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// CodeOwnersRule is a single CODEOWNERS line.
+type CodeOwnersRule struct {
+	Pattern string
+	Owners  []string
+}
+
+// CodeOwners is a parsed CODEOWNERS file.
+type CodeOwners []CodeOwnersRule
+
+// LoadCodeOwners reads the first CODEOWNERS file found below root.
+func LoadCodeOwners(root string) (CodeOwners, error) {
+	for _, path := range codeOwnersPaths {
+		f, err := os.Open(filepath.Join(root, path))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return ParseCodeOwners(f)
+	}
+	return nil, fmt.Errorf("no CODEOWNERS file found")
+}
+
+// ParseCodeOwners parses CODEOWNERS content.
+func ParseCodeOwners(r io.Reader) (CodeOwners, error) {
+	var rules CodeOwners
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.Fields(line)
+		rules = append(rules, CodeOwnersRule{Pattern: parts[0], Owners: parts[1:]})
+	}
+	return rules, scanner.Err()
+}
+
+// Owners returns the owners of file. As in GitHub, the last matching rule
+// wins.
+func (c CodeOwners) Owners(file string) []string {
+	for i := len(c) - 1; i >= 0; i-- {
+		if c[i].matches(file) {
+			return c[i].Owners
+		}
+	}
+	return nil
+}
+
+// OwnersOf returns the distinct owners of files.
+func (c CodeOwners) OwnersOf(files []string) []string {
+	var owners []string
+	seen := map[string]bool{}
+	for _, file := range files {
+		for _, owner := range c.Owners(file) {
+			if !seen[owner] {
+				seen[owner] = true
+				owners = append(owners, owner)
+			}
+		}
+	}
+	return owners
+}
+
+// matches applies the gitignore style CODEOWNERS pattern rules: patterns
+// without a slash match at any depth, a leading slash anchors to the root and
+// a pattern naming a directory matches everything below it.
+func (r CodeOwnersRule) matches(file string) bool {
+	pattern := r.Pattern
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if strings.HasPrefix(pattern, "/") {
+		pattern = pattern[1:]
+	} else if !strings.Contains(strings.TrimSuffix(pattern, "/**"), "/") {
+		pattern = "**/" + pattern
+	}
+	if globMatch(pattern, file) {
+		return true
+	}
+	// A pattern naming a directory also matches everything below it, but
+	// docs/* matches only the direct children of docs.
+	last := pattern[strings.LastIndex(pattern, "/")+1:]
+	return !strings.ContainsAny(last, "*?[") && globMatch(pattern+"/**", file)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testCodeOwners = `# Default owners
+*       @acme/maintainers
+
+/docs/  @octocat # documentation
+*.go    @acme/go-reviewers
+/cmd/   @acme/platform
+`
+
+func TestCodeOwnersOwners(t *testing.T) {
+	owners, err := ParseCodeOwners(strings.NewReader(testCodeOwners))
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"README.md", []string{"@acme/maintainers"}},
+		{"docs/guide/setup.md", []string{"@octocat"}},
+		{"internal/x.go", []string{"@acme/go-reviewers"}},
+		{"cmd/cmd.go", []string{"@acme/platform"}},
+		{"other/docs/a.md", []string{"@acme/maintainers"}},
+	}
+	for _, tt := range tests {
+		if got := owners.Owners(tt.file); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Owners(%q) = %v, expected %v", tt.file, got, tt.want)
+		}
+	}
+
+	got := owners.OwnersOf([]string{"cmd/cmd.go", "cmd/render.go", "docs/a.md"})
+	if want := []string{"@acme/platform", "@octocat"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OwnersOf = %v, expected %v", got, want)
+	}
+}
+
+func TestCodeOwnersRuleMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"docs/*", "docs/a.md", true},
+		{"docs/*", "docs/a/b.md", false},
+		{"/docs/", "docs/a/b.md", true},
+		{"docs", "docs/a/b.md", true},
+		{"docs", "src/docs/a.md", true},
+		{"/build/logs", "build/logs/today.log", true},
+		{"/build/logs", "src/build/logs/today.log", false},
+		{"*.go", "cmd/cmd.go", true},
+		{"*.go", "cmd/cmd.go.txt", false},
+		{"apps/**/test", "apps/web/test/a.js", true},
+	}
+	for _, tt := range tests {
+		if got := (CodeOwnersRule{Pattern: tt.pattern}).matches(tt.file); got != tt.want {
+			t.Errorf("%q matches %q = %v, expected %v", tt.pattern, tt.file, got, tt.want)
+		}
+	}
+}
+
+func TestLoadCodeOwners(t *testing.T) {
+	root := t.TempDir()
+	if _, err := LoadCodeOwners(root); err == nil {
+		t.Error("Expected error when no CODEOWNERS file exists")
+	}
+
+	if err := os.MkdirAll(filepath.Join(root, ".github"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, ".github", "CODEOWNERS"), []byte(testCodeOwners), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	owners, err := LoadCodeOwners(root)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(owners) != 4 {
+		t.Errorf("Expected 4 rules, got %d", len(owners))
+	}
+}
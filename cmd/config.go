@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// defaultConfigFile is read when INPUT_CONFIG_FILE is not set. It is
+	// optional unless a profile is requested.
+	defaultConfigFile = ".github/slack-notify.yml"
+	// configVersion is the only schema version understood by this release.
+	configVersion = 1
+)
+
+// Config is the schema of the configuration file.
+type Config struct {
+	Version  int                `yaml:"version"`
+	Profiles map[string]Profile `yaml:"profiles"`
+	Routes   Routes             `yaml:"routes"`
+	// QuietHours hold back messages that are not urgent.
+	QuietHours QuietHoursList `yaml:"quiet_hours"`
+	// Redact are regular expressions masked in messages in addition to
+	// the built-in secret patterns.
+	Redact []string `yaml:"redact"`
+}
+
+// Profile is a named set of message defaults. Title, text, field values and
+// button urls are Go templates rendered with TemplateData.
+type Profile struct {
+	Channels []string     `yaml:"channels"`
+	Title    string       `yaml:"title"`
+	Text     string       `yaml:"text"`
+	Fields   Fields       `yaml:"fields"`
+	Buttons  Buttons      `yaml:"buttons"`
+	Mention  MentionRules `yaml:"mention"`
+}
+
+// Field is a title/value pair rendered in a two column layout.
+type Field struct {
+	Title string `yaml:"title"`
+	Value string `yaml:"value"`
+}
+
+// Fields is a list of fields. As an input it is written one "Title: value"
+// pair per line.
+type Fields []Field
+
+// UnmarshalEnvironmentValue implements env.Unmarshaler.
+func (f *Fields) UnmarshalEnvironmentValue(data string) error {
+	pairs, err := parsePairs(data)
+	if err != nil {
+		return fmt.Errorf("invalid fields: %v", err)
+	}
+	*f = nil
+	for _, pair := range pairs {
+		*f = append(*f, Field{Title: pair[0], Value: pair[1]})
+	}
+	return nil
+}
+
+// Button is a link rendered below the message.
+type Button struct {
+	Text string `yaml:"text"`
+	URL  string `yaml:"url"`
+}
+
+// Buttons is a list of buttons. As an input it is written one "Text: url"
+// pair per line.
+type Buttons []Button
+
+// UnmarshalEnvironmentValue implements env.Unmarshaler.
+func (b *Buttons) UnmarshalEnvironmentValue(data string) error {
+	pairs, err := parsePairs(data)
+	if err != nil {
+		return fmt.Errorf("invalid buttons: %v", err)
+	}
+	*b = nil
+	for _, pair := range pairs {
+		*b = append(*b, Button{Text: pair[0], URL: pair[1]})
+	}
+	return nil
+}
+
+// parsePairs splits "key: value" lines, skipping blank lines.
+func parsePairs(data string) ([][2]string, error) {
+	var pairs [][2]string
+	for _, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("expected \"key: value\", got %q", line)
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(key), strings.TrimSpace(value)})
+	}
+	return pairs, nil
+}
+
+// stringList accepts either a single YAML scalar or a list of scalars. As an
+// input it is written as values separated by commas or whitespace.
+type stringList []string
+
+func (s *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*s = stringList{node.Value}
+		return nil
+	}
+	var values []string
+	if err := node.Decode(&values); err != nil {
+		return err
+	}
+	*s = values
+	return nil
+}
+
+// UnmarshalEnvironmentValue implements env.Unmarshaler.
+func (s *stringList) UnmarshalEnvironmentValue(data string) error {
+	*s = strings.FieldsFunc(data, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	return nil
+}
+
+// TemplateData is the data available to profile templates.
+type TemplateData struct {
+	GitHubContext
+	Status string
+}
+
+// LoadConfig reads and validates a configuration file. Unknown keys are
+// rejected so that typos do not silently drop settings.
+func LoadConfig(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	var cfg Config
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	if cfg.Version != configVersion {
+		return nil, fmt.Errorf("invalid config file %s: unsupported version %d, expected %d", path, cfg.Version, configVersion)
+	}
+	return &cfg, nil
+}
+
+// applyConfigFile evaluates the routing rules and fills the inputs left
+// empty from the selected profile. Explicit inputs always win over the
+// profile and the route, while a matched route overrides the profile input.
+func applyConfigFile(e *Environment) error {
+	path := e.Config.File
+	if path == "" {
+		path = defaultConfigFile
+	}
+	cfg, err := LoadConfig(path)
+	if errors.Is(err, os.ErrNotExist) && e.Config.Profile == "" {
+		cfg, err = &Config{Version: configVersion}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(e.Config.QuietHours) == 0 {
+		e.Config.QuietHours = cfg.QuietHours
+	}
+	e.Config.Redact = cfg.Redact
+
+	routes := cfg.Routes
+	if len(e.Config.Routes) > 0 {
+		routes = e.Config.Routes
+	}
+	if len(routes) > 0 {
+		route, ok, err := MatchRoute(routes, RouteContext{
+			GitHub:       e.GitHub,
+			Status:       e.Input.Status,
+			ChangedFiles: e.GitHub.ChangedFiles,
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			e.Skip = true
+			return nil
+		}
+		e.Route = route.name()
+		if len(route.Channels) > 0 && e.Slack.Channel == "" {
+			e.Slack.Channel = strings.Join(route.Channels, ",")
+		}
+		if route.Profile != "" {
+			e.Config.Profile = route.Profile
+		}
+		mention := route.Mention
+		mention.merge(e.Mention)
+		e.Mention = mention
+	}
+
+	if e.Config.Profile == "" {
+		return nil
+	}
+	profile, ok := cfg.Profiles[e.Config.Profile]
+	if !ok {
+		return fmt.Errorf("profile %q not found in %s", e.Config.Profile, path)
+	}
+	return profile.apply(e)
+}
+
+func (p Profile) apply(e *Environment) error {
+	data := TemplateData{GitHubContext: e.GitHub, Status: e.Input.Status}
+	render := func(name string, text string) (string, error) {
+		return renderTemplate(e.Config.Profile+"."+name, text, data)
+	}
+
+	var err error
+	if e.Slack.Channel == "" {
+		e.Slack.Channel = strings.Join(p.Channels, ",")
+	}
+	if e.Input.Title == "" {
+		if e.Input.Title, err = render("title", p.Title); err != nil {
+			return err
+		}
+	}
+	if e.Input.Text == "" {
+		if e.Input.Text, err = render("text", p.Text); err != nil {
+			return err
+		}
+	}
+	if len(e.Input.Fields) == 0 {
+		for _, field := range p.Fields {
+			if field.Value, err = render("fields."+field.Title, field.Value); err != nil {
+				return err
+			}
+			e.Input.Fields = append(e.Input.Fields, field)
+		}
+	}
+	if len(e.Input.Buttons) == 0 {
+		for _, button := range p.Buttons {
+			if button.URL, err = render("buttons."+button.Text, button.URL); err != nil {
+				return err
+			}
+			e.Input.Buttons = append(e.Input.Buttons, button)
+		}
+	}
+	e.Mention.merge(p.Mention)
+	return nil
+}
+
+func renderTemplate(name string, text string, data any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid template %s: %v", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering template %s: %v", name, err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// DedupeRecord is the message first posted for a dedupe key.
+type DedupeRecord struct {
+	Channel string `json:"channel"`
+	TS      string `json:"ts"`
+	// Count is how often the message was sent, the first time included.
+	Count int `json:"count"`
+}
+
+// dedupeStore remembers the messages posted for dedupe keys.
+type dedupeStore interface {
+	Get(key string) (DedupeRecord, bool, error)
+	Put(key string, record DedupeRecord) error
+}
+
+// newDedupeStore returns the store selected by INPUT_STATE_BACKEND. The file
+// backend keeps the records next to the state file rather than in it, so
+// that they do not mix with the message keys.
+func newDedupeStore(e *Environment, channel string) (dedupeStore, error) {
+	switch e.Slack.StateBackend {
+	case "", StateBackendFile:
+		path := e.Slack.StateFile
+		if path == "" {
+			path = defaultStateFile
+		}
+		return &recordFile[DedupeRecord]{path: strings.TrimSuffix(path, ".json") + ".dedupe.json"}, nil
+	case StateBackendDirectory:
+		dir := e.Slack.StateDir
+		if dir == "" {
+			dir = defaultStateDir
+		}
+		return &recordDir[DedupeRecord]{dir: dir}, nil
+	case StateBackendChannel:
+		return &dedupeChannelStore{api: slackAPI, channel: channel}, nil
+	}
+	return nil, fmt.Errorf("invalid state_backend %q, expected %s, %s or %s", e.Slack.StateBackend, StateBackendFile, StateBackendDirectory, StateBackendChannel)
+}
+
+// dedupeChannelStore finds the message of a key in the channel history by
+// its marker. The repeats are counted by the replies in its thread, so the
+// count only grows with dedupe_reply.
+type dedupeChannelStore struct {
+	api     slackService
+	channel string
+}
+
+func (s *dedupeChannelStore) Get(key string) (DedupeRecord, bool, error) {
+	marker := keyMarker(key)
+	messages, err := s.api.History(s.channel, channelHistoryLimit)
+	if err != nil {
+		return DedupeRecord{}, false, fmt.Errorf("error searching %s for message %s: %v", s.channel, key, err)
+	}
+	for _, message := range messages {
+		if message.hasBlock(marker) {
+			return DedupeRecord{Channel: s.channel, TS: message.Timestamp, Count: message.ReplyCount + 1}, true, nil
+		}
+	}
+	return DedupeRecord{}, false, nil
+}
+
+// Put does nothing; the marker is part of the posted message.
+func (s *dedupeChannelStore) Put(key string, record DedupeRecord) error {
+	return nil
+}
+
+// dedupeKey is the store key of message in channel: a hash of the channel
+// and INPUT_DEDUPE_KEY, or of the rendered message when no key is set. It is
+// hashed to keep it usable as a file name.
+func dedupeKey(channel string, key string, message slack.Message) (string, error) {
+	if key == "" {
+		content, err := json.Marshal(message)
+		if err != nil {
+			return "", err
+		}
+		key = "message:" + string(content)
+	}
+	sum := sha256.Sum256([]byte(channel + "\n" + key))
+	return "dedupe-" + hex.EncodeToString(sum[:16]), nil
+}
+
+// slackTime returns the time of a message from its ts.
+func slackTime(ts string) (time.Time, error) {
+	seconds, micros, _ := strings.Cut(ts, ".")
+	s, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid message ts %q", ts)
+	}
+	var us int64
+	if micros != "" {
+		if us, err = strconv.ParseInt(micros, 10, 64); err != nil {
+			return time.Time{}, fmt.Errorf("invalid message ts %q", ts)
+		}
+	}
+	return time.Unix(s, us*int64(time.Microsecond)), nil
+}
+
+// RepeatMessage is the thread reply noting that a suppressed message
+// happened again.
+func RepeatMessage(record DedupeRecord) slack.Message {
+	return slack.Message{
+		Channel: record.Channel,
+		Thread:  record.TS,
+		Text:    fmt.Sprintf("Happened again (×%d)", record.Count),
+	}
+}
+
+// dedupe posts message to channel unless the same message was posted there
+// within window. A suppressed message is counted and, with reply, noted in
+// the thread of the first one. It returns the message posted, or the first
+// one when suppressed, and whether the message was suppressed.
+func dedupe(poster messagePoster, store dedupeStore, key string, channel string, message slack.Message, window time.Duration, reply bool) (slack.MessageRef, bool, error) {
+	if len(message.Blocks) > 0 {
+		message.Blocks[0].BlockId = keyMarker(key)
+	}
+
+	record, ok, err := store.Get(key)
+	if err != nil {
+		return slack.MessageRef{}, false, fmt.Errorf("error while looking up dedupe key: %v", err)
+	}
+	if ok {
+		posted, err := slackTime(record.TS)
+		if err != nil {
+			return slack.MessageRef{}, false, err
+		}
+		if timeNow().Sub(posted) < window {
+			record.Count++
+			ref := slack.MessageRef{Channel: record.Channel, Timestamp: record.TS}
+			if reply {
+				if _, err := poster.AddFormattedMessage(record.Channel, RepeatMessage(record)); err != nil {
+					return ref, true, fmt.Errorf("error while replying to duplicate message: %w", err)
+				}
+			}
+			if err := store.Put(key, record); err != nil {
+				return ref, true, fmt.Errorf("error while recording duplicate message: %v", err)
+			}
+			logger.Noticef("same message posted to %s at %s, suppressed (sent %d times)", record.Channel, posted.UTC().Format(time.RFC3339), record.Count)
+			return ref, true, nil
+		}
+	}
+
+	ref, err := poster.AddFormattedMessage(channel, message)
+	if err != nil {
+		return ref, false, fmt.Errorf("error while sending message to slack: %w", err)
+	}
+	if err := store.Put(key, DedupeRecord{Channel: ref.Channel, TS: ref.Timestamp, Count: 1}); err != nil {
+		return ref, false, fmt.Errorf("error while recording message for dedupe: %v", err)
+	}
+	return ref, false, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setTimeNow replaces the clock of the action for the test.
+func setTimeNow(t *testing.T, now time.Time) {
+	t.Helper()
+	saved := timeNow
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = saved })
+}
+
+func TestSlackTime(t *testing.T) {
+	tests := []struct {
+		ts       string
+		expected time.Time
+		err      bool
+	}{
+		{"1700000000.000100", time.Unix(1700000000, 100000), false},
+		{"1700000000", time.Unix(1700000000, 0), false},
+		{"", time.Time{}, true},
+		{"1700000000.abc", time.Time{}, true},
+	}
+	for _, test := range tests {
+		got, err := slackTime(test.ts)
+		if (err != nil) != test.err {
+			t.Errorf("slackTime(%q): unexpected error %v", test.ts, err)
+			continue
+		}
+		if !got.Equal(test.expected) {
+			t.Errorf("slackTime(%q): expected %s, got %s", test.ts, test.expected, got)
+		}
+	}
+}
+
+func TestDedupeKey(t *testing.T) {
+	message := SlackMessageBuilder("Deploy", "Deploying to production", "general")
+	other := SlackMessageBuilder("Deploy", "Deploying to staging", "general")
+
+	first, _ := dedupeKey("general", "", message)
+	second, _ := dedupeKey("general", "", message)
+	if first != second {
+		t.Errorf("Expected the same key for the same message, got %s and %s", first, second)
+	}
+	if key, _ := dedupeKey("general", "", other); key == first {
+		t.Error("Expected different keys for different messages")
+	}
+	if key, _ := dedupeKey("ops", "", message); key == first {
+		t.Error("Expected different keys for different channels")
+	}
+	byKey, _ := dedupeKey("general", "nightly", message)
+	if key, _ := dedupeKey("general", "nightly", other); key != byKey {
+		t.Error("Expected INPUT_DEDUPE_KEY to identify the message regardless of its content")
+	}
+}
+
+func TestPostDedupe(t *testing.T) {
+	message := map[string]string{
+		"INPUT_TITLE":         "Nightly build failed",
+		"INPUT_TEXT":          "The nightly build failed",
+		"INPUT_SLACK_CHANNEL": "general",
+		"INPUT_DEDUPE_WINDOW": "1h",
+	}
+	with := func(env map[string]string, extra map[string]string) map[string]string {
+		merged := map[string]string{}
+		for key, value := range env {
+			merged[key] = value
+		}
+		for key, value := range extra {
+			merged[key] = value
+		}
+		return merged
+	}
+
+	t.Run("Suppressed within the window", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		env := with(message, map[string]string{"INPUT_STATE_FILE": filepath.Join(t.TempDir(), "messages.json")})
+		setTimeNow(t, time.Unix(1700000000, 0).Add(10*time.Minute))
+
+		outputs, err := runWithFakeSlack(t, fake, env)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !strings.Contains(outputs, "suppressed=false\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+		outputs, err = runWithFakeSlack(t, fake, env)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !strings.Contains(outputs, "suppressed=true\n") || !strings.Contains(outputs, "ts=1700000000.000001\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+		if strings.Contains(outputs, "delivered_via") {
+			t.Errorf("Expected no delivered_via for a suppressed message, got %q", outputs)
+		}
+		if strings.Contains(outputs, "permalink") {
+			t.Errorf("Expected no permalink for a suppressed message, got %q", outputs)
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 1 {
+			t.Errorf("Expected a single call of chat.postMessage, got %d", calls)
+		}
+	})
+
+	t.Run("Posted again after the window", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		env := with(message, map[string]string{"INPUT_STATE_FILE": filepath.Join(t.TempDir(), "messages.json")})
+		setTimeNow(t, time.Unix(1700000000, 0).Add(2*time.Hour))
+
+		for i := 0; i < 2; i++ {
+			outputs, err := runWithFakeSlack(t, fake, env)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if !strings.Contains(outputs, "suppressed=false\n") {
+				t.Errorf("Unexpected outputs %q", outputs)
+			}
+		}
+		if messages := fake.channelMessages("C0GENERAL"); len(messages) != 2 {
+			t.Errorf("Expected 2 messages in the channel, got %d", len(messages))
+		}
+	})
+
+	t.Run("Different message", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		state := filepath.Join(t.TempDir(), "messages.json")
+		setTimeNow(t, time.Unix(1700000000, 0))
+
+		if _, err := runWithFakeSlack(t, fake, with(message, map[string]string{"INPUT_STATE_FILE": state})); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		outputs, err := runWithFakeSlack(t, fake, with(message, map[string]string{"INPUT_STATE_FILE": state, "INPUT_TEXT": "The nightly build failed again"}))
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !strings.Contains(outputs, "suppressed=false\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Thread reply with the channel backend", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		env := with(message, map[string]string{
+			"INPUT_STATE_BACKEND": StateBackendChannel,
+			"INPUT_DEDUPE_KEY":    "nightly",
+			"INPUT_DEDUPE_REPLY":  "true",
+		})
+		setTimeNow(t, time.Unix(1700000000, 0))
+
+		for i := 0; i < 3; i++ {
+			if _, err := runWithFakeSlack(t, fake, env); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+		}
+		messages := fake.channelMessages("C0GENERAL")
+		if len(messages) != 1 {
+			t.Fatalf("Expected 1 message in the channel, got %d", len(messages))
+		}
+		if messages[0].ReplyCount != 2 {
+			t.Errorf("Expected 2 replies, got %d", messages[0].ReplyCount)
+		}
+		replies := fake.requestsFor("chat.postMessage")[1:]
+		if len(replies) != 2 {
+			t.Fatalf("Expected 2 replies, got %d", len(replies))
+		}
+		for i, reply := range replies {
+			if thread := reply.param("thread_ts"); thread != messages[0].TS {
+				t.Errorf("Expected a reply in thread %s, got %q", messages[0].TS, thread)
+			}
+			if expected := []string{"Happened again (×2)", "Happened again (×3)"}[i]; reply.param("text") != expected {
+				t.Errorf("Expected reply %q, got %q", expected, reply.param("text"))
+			}
+		}
+	})
+
+	t.Run("Directory backend", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		env := with(message, map[string]string{
+			"INPUT_STATE_BACKEND": StateBackendDirectory,
+			"INPUT_STATE_DIR":     t.TempDir(),
+		})
+		setTimeNow(t, time.Unix(1700000000, 0))
+
+		for i := 0; i < 2; i++ {
+			if _, err := runWithFakeSlack(t, fake, env); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 1 {
+			t.Errorf("Expected a single call of chat.postMessage, got %d", calls)
+		}
+	})
+
+	t.Run("Invalid inputs", func(t *testing.T) {
+		tests := []map[string]string{
+			{"INPUT_DEDUPE_WINDOW": "soon"},
+			{"INPUT_DEDUPE_WINDOW": "-1h"},
+			{"INPUT_DEDUPE_WINDOW": "", "INPUT_DEDUPE_KEY": "nightly"},
+			{"INPUT_MESSAGE_KEY": "nightly"},
+			{"INPUT_POST_AT": "+1h"},
+		}
+		for _, extra := range tests {
+			if _, err := runWithFakeSlack(t, newFakeSlack(t), with(message, extra)); err == nil {
+				t.Errorf("Expected an error for %v", extra)
+			}
+		}
+	})
+}
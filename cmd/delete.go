@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// messageDeleter deletes posted messages.
+type messageDeleter interface {
+	DeleteMessage(item slack.MessageRef) error
+}
+
+// DeleteMessage deletes a message posted by the app.
+func (w *webAPI) DeleteMessage(item slack.MessageRef) error {
+	return w.callJSON("chat.delete", map[string]string{
+		"channel": item.Channel,
+		"ts":      item.Timestamp,
+	}, nil)
+}
+
+// deleteRequest identifies the message to delete, either directly or by the
+// key it was recorded under.
+type deleteRequest struct {
+	Item slack.MessageRef
+	Key  string
+	// Idempotent treats a message that is already gone as deleted.
+	Idempotent bool
+	DryRun     bool
+}
+
+// deleteMessage deletes the message of req. A key is looked up in store and
+// forgotten once the message is deleted.
+func deleteMessage(client messageDeleter, store MessageStore, req deleteRequest) error {
+	item := req.Item
+	if req.Key != "" {
+		ref, ok, err := store.Get(req.Key)
+		if err != nil {
+			return fmt.Errorf("error while looking up message %s: %v", req.Key, err)
+		}
+		if !ok {
+			if req.Idempotent {
+				logger.Infof("no message recorded for key %s, nothing to delete", req.Key)
+				return nil
+			}
+			return fmt.Errorf("no message recorded for key %s", req.Key)
+		}
+		item = ref
+	}
+
+	if req.DryRun {
+		logger.Infof("dry run: would delete message %s in %s", item.Timestamp, item.Channel)
+		return nil
+	}
+	err := client.DeleteMessage(item)
+	if err != nil && !(req.Idempotent && IsSlackError(err, "message_not_found")) {
+		return fmt.Errorf("error while deleting message: %v", err)
+	}
+	if req.Key != "" {
+		return store.Delete(req.Key)
+	}
+	return nil
+}
+
+// deleteFromEnv deletes the message identified by the inputs.
+func deleteFromEnv() error {
+	store, err := newMessageStore(&envVar)
+	if err != nil {
+		return err
+	}
+	return deleteMessage(slackAPI, store, deleteRequest{
+		Item:       slack.MessageRef{Channel: envVar.Slack.Channel, Timestamp: envVar.Slack.MessageTS},
+		Key:        envVar.Slack.MessageKey,
+		Idempotent: envVar.Slack.Idempotent,
+		DryRun:     envVar.Input.DryRun,
+	})
+}
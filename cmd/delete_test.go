@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+func TestWebAPIDeleteMessage(t *testing.T) {
+	var body map[string]string
+	api := newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.delete" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	err := api.DeleteMessage(slack.MessageRef{Channel: "C0123", Timestamp: "1700000000.000100"})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if body["channel"] != "C0123" || body["ts"] != "1700000000.000100" {
+		t.Errorf("Unexpected request body %v", body)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	ref := slack.MessageRef{Channel: "C0123", Timestamp: "1700000000.000100"}
+	notFound := &SlackError{Method: "chat.delete", Code: "message_not_found"}
+
+	tests := []struct {
+		name        string
+		req         deleteRequest
+		recorded    bool
+		response    error
+		expectErr   bool
+		expectCalls int
+	}{
+		{name: "By ts", req: deleteRequest{Item: ref}, expectCalls: 1},
+		{name: "By label", req: deleteRequest{Key: "progress"}, recorded: true, expectCalls: 1},
+		{name: "Unknown label", req: deleteRequest{Key: "progress"}, expectErr: true},
+		{name: "Unknown label idempotent", req: deleteRequest{Key: "progress", Idempotent: true}},
+		{name: "Already deleted", req: deleteRequest{Item: ref}, response: notFound, expectErr: true, expectCalls: 1},
+		{name: "Already deleted idempotent", req: deleteRequest{Key: "progress", Idempotent: true}, recorded: true, response: notFound, expectCalls: 1},
+		{name: "Dry run", req: deleteRequest{Key: "progress", DryRun: true}, recorded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFileStore(filepath.Join(t.TempDir(), "messages.json"))
+			if tt.recorded {
+				store.Put("progress", ref)
+			}
+			client := &MockSlackClient{Responses: []MockSlackResponse{{Error: tt.response}}}
+
+			err := deleteMessage(client, store, tt.req)
+			if tt.expectErr != (err != nil) {
+				t.Fatalf("Expected error %v, got %v", tt.expectErr, err)
+			}
+			if client.CallCount != tt.expectCalls {
+				t.Errorf("Expected %d calls, got %d", tt.expectCalls, client.CallCount)
+			}
+			if tt.expectCalls > 0 && client.Deleted[0] != ref {
+				t.Errorf("Expected %+v to be deleted, got %+v", ref, client.Deleted[0])
+			}
+			_, stillRecorded, _ := store.Get("progress")
+			if tt.recorded && !tt.expectErr && !tt.req.DryRun && stillRecorded {
+				t.Error("Expected the label to be forgotten after deleting")
+			}
+		})
+	}
+}
+
+func TestInitializeAppDelete(t *testing.T) {
+	SetupTestEnvironment()
+	defer CleanupTestEnvironment()
+	t.Setenv("INPUT_OPERATION", "delete")
+
+	if err := initializeApp(); err == nil {
+		t.Error("Expected an error without message_ts or message_key")
+	}
+
+	t.Setenv("INPUT_SLACK_CHANNEL", "")
+	t.Setenv("INPUT_MESSAGE_KEY", "progress")
+	if err := initializeApp(); err != nil {
+		t.Errorf("Expected a key to be enough, got %v", err)
+	}
+}
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// digestEventType marks the metadata holding the state of a digest.
+const digestEventType = "message_slack_digest"
+
+const (
+	// maxDigestAttempts is how often an invocation tries to add its entry
+	// before giving up on concurrent writers.
+	maxDigestAttempts = 8
+	// digestSettle is how long a writer waits before checking that its
+	// update was not overwritten. It must exceed the time between another
+	// writer's version check and its update.
+	digestSettle = time.Second
+)
+
+// DigestEntry is the result one invocation adds to a digest.
+type DigestEntry struct {
+	Job      string `json:"job"`
+	Status   string `json:"status"`
+	Duration string `json:"duration,omitempty"`
+}
+
+// DigestState is the content of a digest. It is kept in the metadata of the
+// digest message, the only state the jobs of a matrix share. Version grows
+// with every update, so that writers can detect that they raced.
+type DigestState struct {
+	Key     string        `json:"key"`
+	Version int           `json:"version"`
+	Total   int           `json:"total,omitempty"`
+	Entries []DigestEntry `json:"entries"`
+}
+
+// has reports whether the state holds entry unchanged.
+func (s DigestState) has(entry DigestEntry) bool {
+	for _, e := range s.Entries {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
+
+// with returns the next version of the state with entry added, replacing an
+// earlier entry of the same job.
+func (s DigestState) with(entry DigestEntry) DigestState {
+	next := DigestState{Key: s.Key, Version: s.Version + 1, Total: s.Total}
+	for _, e := range s.Entries {
+		if e.Job != entry.Job {
+			next.Entries = append(next.Entries, e)
+		}
+	}
+	next.Entries = append(next.Entries, entry)
+	sort.Slice(next.Entries, func(i, j int) bool { return next.Entries[i].Job < next.Entries[j].Job })
+	return next
+}
+
+func (s DigestState) metadata() (*MessageMetadata, error) {
+	content, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(content, &payload); err != nil {
+		return nil, err
+	}
+	return &MessageMetadata{EventType: digestEventType, EventPayload: payload}, nil
+}
+
+func digestStateFromMetadata(metadata *MessageMetadata) (DigestState, error) {
+	var state DigestState
+	if metadata == nil || metadata.EventType != digestEventType {
+		return state, fmt.Errorf("message has no digest metadata")
+	}
+	content, err := json.Marshal(metadata.EventPayload)
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(content, &state)
+	return state, err
+}
+
+// digestMarker is the block ID that marks the message of a digest.
+func digestMarker(key string) string {
+	return keyMarker("digest:" + key)
+}
+
+// DigestMessage renders state as a message: text, if any, followed by a
+// summary of the statuses and one line per job.
+func DigestMessage(title string, text string, state DigestState, channel string) slack.Message {
+	counts := map[string]int{}
+	for _, entry := range state.Entries {
+		counts[entry.Status]++
+	}
+	var statuses []string
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	summary := make([]string, 0, len(statuses))
+	for _, status := range statuses {
+		summary = append(summary, fmt.Sprintf("%d %s", counts[status], status))
+	}
+
+	var b strings.Builder
+	if text != "" {
+		b.WriteString(text + "\n\n")
+	}
+	if state.Total > 0 {
+		fmt.Fprintf(&b, "*%d of %d jobs reported*", len(state.Entries), state.Total)
+	} else {
+		fmt.Fprintf(&b, "*%d jobs reported*", len(state.Entries))
+	}
+	fmt.Fprintf(&b, ": %s\n", strings.Join(summary, ", "))
+	for _, entry := range state.Entries {
+		emoji := statusReactions[entry.Status]
+		if emoji == "" {
+			emoji = "grey_question"
+		}
+		line := fmt.Sprintf(":%s: `%s` %s", emoji, entry.Job, entry.Status)
+		if entry.Duration != "" {
+			line += " · " + entry.Duration
+		}
+		b.WriteString(line + "\n")
+	}
+
+	message := SlackMessageBuilder(title, truncate(strings.TrimSpace(b.String()), maxSectionTextLength), channel)
+	message.Blocks[0].BlockId = digestMarker(state.Key)
+	return message
+}
+
+// digestMessage is a digest found in the channel.
+type digestMessage struct {
+	ref   slack.MessageRef
+	state DigestState
+}
+
+// digestWriter adds entries to the digest posted in a channel.
+//
+// Slack offers no compare-and-swap, so updates are optimistic and not
+// atomic: a writer checks that the version it read is still current right
+// before updating, and after a while checks that its entry survived,
+// starting over when it did not. The version check and the update are two
+// calls, so two writers can both pass the check and the later update
+// overwrites the earlier one. The check after the settle time catches that
+// as long as the overwrite lands within it; an update that Slack delays by
+// more than the settle time can still drop an entry unnoticed.
+type digestWriter struct {
+	api     slackService
+	channel string
+	title   string
+	text    string
+	// retry repeats the Slack calls that fail with a transient error.
+	retry retryPolicy
+	// settle and sleep wait between the steps; tests shorten them.
+	settle time.Duration
+	sleep  func(time.Duration)
+}
+
+func newDigestWriter(api slackService, channel string, title string, text string, retries int) *digestWriter {
+	return &digestWriter{
+		api:     api,
+		channel: channel,
+		title:   title,
+		text:    text,
+		retry:   newRetryPolicy(retries),
+		settle:  digestSettle,
+		sleep:   retrySleep,
+	}
+}
+
+// find returns the digest for key, or nil if there is none. When writers
+// raced to post it, the oldest message is the digest.
+func (d *digestWriter) find(key string) (*digestMessage, error) {
+	var messages []HistoryMessage
+	err := d.retry.do("conversations.history", func() error {
+		var err error
+		messages, err = d.api.History(d.channel, channelHistoryLimit)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error searching %s for digest %s: %v", d.channel, key, err)
+	}
+	marker := digestMarker(key)
+	var found *digestMessage
+	for _, message := range messages {
+		if !message.hasBlock(marker) {
+			continue
+		}
+		state, err := digestStateFromMetadata(message.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest %s in %s: %v", key, d.channel, err)
+		}
+		found = &digestMessage{ref: slack.MessageRef{Channel: d.channel, Timestamp: message.Timestamp}, state: state}
+	}
+	return found, nil
+}
+
+// wait sleeps for the settle time with some jitter, so that writers that
+// collided do not collide again.
+func (d *digestWriter) wait() {
+	d.sleep(d.settle + time.Duration(rand.Int63n(int64(d.settle)+1)))
+}
+
+// Add adds entry to the digest for key, posting the digest if it does not
+// exist yet, and returns the digest message.
+func (d *digestWriter) Add(key string, total int, entry DigestEntry) (slack.MessageRef, error) {
+	for attempt := 1; attempt <= maxDigestAttempts; attempt++ {
+		current, err := d.find(key)
+		if err != nil {
+			return slack.MessageRef{}, err
+		}
+
+		if current == nil {
+			state := DigestState{Key: key, Total: total}.with(entry)
+			ref, err := d.post(state)
+			if err != nil {
+				return ref, err
+			}
+			winner, err := d.find(key)
+			if err != nil {
+				return ref, err
+			}
+			if winner == nil || winner.ref.Timestamp == ref.Timestamp {
+				return ref, nil
+			}
+			// Another writer posted first; merge into its message instead.
+			logger.Debugf("digest %s was posted concurrently, merging into %s", key, winner.ref.Timestamp)
+			err = d.retry.do("chat.delete", func() error { return d.api.DeleteMessage(ref) })
+			if err != nil && !IsSlackError(err, "message_not_found") {
+				return ref, fmt.Errorf("error while deleting duplicate digest: %v", err)
+			}
+			continue
+		}
+
+		if current.state.has(entry) {
+			return current.ref, nil
+		}
+		next := current.state.with(entry)
+		if total > 0 {
+			next.Total = total
+		}
+		// Not a compare-and-swap, see digestWriter: this only narrows the
+		// window in which a concurrent update is lost.
+		latest, err := d.find(key)
+		if err != nil {
+			return current.ref, err
+		}
+		if latest == nil || latest.state.Version != current.state.Version {
+			logger.Debugf("digest %s changed while updating (attempt %d of %d), retrying", key, attempt, maxDigestAttempts)
+			d.wait()
+			continue
+		}
+		if err := d.update(current.ref, next); err != nil {
+			return current.ref, err
+		}
+
+		d.wait()
+		check, err := d.find(key)
+		if err != nil {
+			return current.ref, err
+		}
+		if check != nil && check.state.has(entry) {
+			return check.ref, nil
+		}
+		logger.Debugf("digest %s was overwritten by a concurrent update (attempt %d of %d), retrying", key, attempt, maxDigestAttempts)
+	}
+	return slack.MessageRef{}, fmt.Errorf("could not add %s to digest %s after %d attempts", entry.Job, key, maxDigestAttempts)
+}
+
+func (d *digestWriter) post(state DigestState) (slack.MessageRef, error) {
+	metadata, err := state.metadata()
+	if err != nil {
+		return slack.MessageRef{}, err
+	}
+	var ref slack.MessageRef
+	err = d.retry.do("chat.postMessage", func() error {
+		var err error
+		ref, err = d.api.PostMessage(d.channel, DigestMessage(d.title, d.text, state, d.channel), metadata)
+		return err
+	})
+	if err != nil {
+		return ref, fmt.Errorf("error while posting digest: %w", err)
+	}
+	// History is read from the channel the message ended up in, which is
+	// an ID even if the channel was given by name.
+	d.channel = ref.Channel
+	return ref, nil
+}
+
+func (d *digestWriter) update(ref slack.MessageRef, state DigestState) error {
+	metadata, err := state.metadata()
+	if err != nil {
+		return err
+	}
+	err = d.retry.do("chat.update", func() error {
+		return d.api.updateMessage(ref, DigestMessage(d.title, d.text, state, ref.Channel), metadata)
+	})
+	if err != nil {
+		return fmt.Errorf("error while updating digest: %w", err)
+	}
+	return nil
+}
+
+// digestEntry is the entry of this invocation.
+func (e *Environment) digestEntry() DigestEntry {
+	job := e.Digest.Job
+	if job == "" {
+		job = e.GitHub.Job
+	}
+	return DigestEntry{Job: job, Status: e.Input.Status, Duration: e.Digest.Duration}
+}
+
+// digest adds the result of this job to the digest message.
+func digest() error {
+	channel := envVar.Channels()[0]
+	entry := envVar.digestEntry()
+	if envVar.Input.DryRun {
+		state := DigestState{Key: envVar.Digest.Key, Total: envVar.Digest.Total}.with(entry)
+		return renderMessage(os.Stdout, DigestMessage(envVar.Input.Title, envVar.Input.Text, state, channel), nil)
+	}
+
+	writer := newDigestWriter(slackAPI, channel, envVar.Input.Title, envVar.Input.Text, envVar.Slack.Retries)
+	ref, err := writer.Add(envVar.Digest.Key, envVar.Digest.Total, entry)
+	if err != nil {
+		return err
+	}
+	logger.Infof("added %s to digest %s", entry.Job, envVar.Digest.Key)
+	return setPostOutputs([]string{ref.Channel}, []string{ref.Timestamp}, nil)
+}
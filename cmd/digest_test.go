@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDigestState(t *testing.T) {
+	state := DigestState{Key: "ci"}.
+		with(DigestEntry{Job: "test", Status: "failure"}).
+		with(DigestEntry{Job: "build", Status: "success", Duration: "2m"})
+	if state.Version != 2 || len(state.Entries) != 2 || state.Entries[0].Job != "build" {
+		t.Fatalf("Expected two sorted entries at version 2, got %+v", state)
+	}
+
+	state = state.with(DigestEntry{Job: "test", Status: "success"})
+	if state.Version != 3 || len(state.Entries) != 2 || !state.has(DigestEntry{Job: "test", Status: "success"}) {
+		t.Errorf("Expected the entry of the job to be replaced, got %+v", state)
+	}
+	if state.has(DigestEntry{Job: "test", Status: "failure"}) {
+		t.Error("Expected the old entry to be gone")
+	}
+
+	metadata, err := state.metadata()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	decoded, err := digestStateFromMetadata(metadata)
+	if err != nil || fmt.Sprint(decoded) != fmt.Sprint(state) {
+		t.Errorf("Expected the state to survive the metadata, got %+v (%v)", decoded, err)
+	}
+	if _, err := digestStateFromMetadata(&MessageMetadata{EventType: "other"}); err == nil {
+		t.Error("Expected other metadata to be rejected")
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	state := DigestState{Key: "ci", Total: 3}.
+		with(DigestEntry{Job: "build", Status: "success", Duration: "4m12s"}).
+		with(DigestEntry{Job: "lint", Status: "failure"})
+	message := DigestMessage("CI matrix", "Run 42", state, "general")
+
+	if message.Blocks[0].BlockId != digestMarker("ci") {
+		t.Errorf("Expected the digest marker, got %q", message.Blocks[0].BlockId)
+	}
+	text := message.Blocks[1].Text.Text
+	want := "Run 42\n\n*2 of 3 jobs reported*: 1 failure, 1 success\n" +
+		":white_check_mark: `build` success · 4m12s\n" +
+		":x: `lint` failure"
+	if text != want {
+		t.Errorf("Expected text %q, got %q", want, text)
+	}
+	payload, _ := json.Marshal(message)
+	if problems := ValidateBlockKit(payload); len(problems) > 0 {
+		t.Errorf("Expected valid Block Kit, got %v", problems)
+	}
+}
+
+func newTestDigestWriter(fake *fakeSlack, settle time.Duration) *digestWriter {
+	api := newWebAPI(fakeSlackToken)
+	api.baseURL = fake.URL
+	writer := newDigestWriter(api, "C0GENERAL", "CI matrix", "", 2)
+	writer.settle = settle
+	writer.sleep = time.Sleep
+	writer.retry.sleep = nil
+	return writer
+}
+
+func TestDigestWriter(t *testing.T) {
+	fake := newFakeSlack(t)
+	var first string
+	for i, entry := range []DigestEntry{
+		{Job: "build", Status: "success"},
+		{Job: "test", Status: "failure"},
+		{Job: "test", Status: "success"},
+	} {
+		ref, err := newTestDigestWriter(fake, 0).Add("ci", 2, entry)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if i == 0 {
+			first = ref.Timestamp
+		} else if ref.Timestamp != first {
+			t.Errorf("Expected every entry to go to %s, got %s", first, ref.Timestamp)
+		}
+	}
+
+	messages := fake.channelMessages("C0GENERAL")
+	if len(messages) != 1 {
+		t.Fatalf("Expected a single digest message, got %d", len(messages))
+	}
+	state := fakeDigestState(t, messages[0])
+	if state.Version != 3 || len(state.Entries) != 2 || !state.has(DigestEntry{Job: "test", Status: "success"}) {
+		t.Errorf("Unexpected digest state %+v", state)
+	}
+	if updates := fake.requestsFor("chat.update"); len(updates) != 2 {
+		t.Errorf("Expected 2 updates, got %d", len(updates))
+	}
+}
+
+func TestDigestWriterRetries(t *testing.T) {
+	fake := newFakeSlack(t)
+	fake.failNext("conversations.history", "internal_error")
+	fake.failNext("chat.postMessage", "ratelimited")
+	if _, err := newTestDigestWriter(fake, 0).Add("ci", 2, DigestEntry{Job: "build", Status: "success"}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	fake.failNext("conversations.history", "service_unavailable")
+	fake.failNext("chat.update", "internal_error")
+	if _, err := newTestDigestWriter(fake, 0).Add("ci", 2, DigestEntry{Job: "test", Status: "success"}); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	messages := fake.channelMessages("C0GENERAL")
+	if len(messages) != 1 {
+		t.Fatalf("Expected a single digest message, got %d", len(messages))
+	}
+	if state := fakeDigestState(t, messages[0]); len(state.Entries) != 2 {
+		t.Errorf("Expected both entries, got %+v", state.Entries)
+	}
+	if updates := fake.requestsFor("chat.update"); len(updates) != 2 {
+		t.Errorf("Expected the failed update to be repeated, got %d updates", len(updates))
+	}
+}
+
+func TestDigestWriterConcurrent(t *testing.T) {
+	fake := newFakeSlack(t)
+	const jobs = 6
+
+	var wg sync.WaitGroup
+	errs := make(chan error, jobs)
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := newTestDigestWriter(fake, 50*time.Millisecond).Add("ci", jobs, DigestEntry{Job: fmt.Sprintf("job-%d", i), Status: "success"})
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+	}
+
+	messages := fake.channelMessages("C0GENERAL")
+	if len(messages) != 1 {
+		t.Fatalf("Expected concurrent writers to end up with a single message, got %d", len(messages))
+	}
+	if state := fakeDigestState(t, messages[0]); len(state.Entries) != jobs {
+		t.Errorf("Expected all %d entries, got %+v", jobs, state.Entries)
+	}
+}
+
+func TestDigestEndToEnd(t *testing.T) {
+	noRetrySleep(t)
+	fake := newFakeSlack(t)
+	for _, job := range []string{"build (linux)", "build (macos)"} {
+		outputs, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_TITLE":         "CI matrix",
+			"INPUT_STATUS":        "success",
+			"INPUT_SLACK_CHANNEL": "general",
+			"INPUT_DIGEST_KEY":    "run-42",
+			"INPUT_DIGEST_JOB":    job,
+			"INPUT_DURATION":      "3m",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !strings.Contains(outputs, "ts=1700000000.000001\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	}
+	messages := fake.channelMessages("C0GENERAL")
+	if len(messages) != 1 || len(fakeDigestState(t, messages[0]).Entries) != 2 {
+		t.Errorf("Expected one digest with both jobs, got %+v", messages)
+	}
+}
+
+func fakeDigestState(t *testing.T, message fakeMessage) DigestState {
+	t.Helper()
+	content, _ := json.Marshal(message.Metadata)
+	var metadata MessageMetadata
+	if err := json.Unmarshal(content, &metadata); err != nil {
+		t.Fatalf("Expected digest metadata, got %s", content)
+	}
+	state, err := digestStateFromMetadata(&metadata)
+	if err != nil {
+		t.Fatalf("Expected digest metadata, got %v", err)
+	}
+	return state
+}
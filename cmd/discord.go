@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Discord embed limits.
+const (
+	maxDiscordTitleLength       = 256
+	maxDiscordDescriptionLength = 4096
+	maxDiscordFields            = 25
+	maxDiscordFieldNameLength   = 256
+	maxDiscordFieldValueLength  = 1024
+	maxDiscordEmbedLength       = 6000
+)
+
+// discordProvider posts embeds to a Discord webhook.
+type discordProvider struct{}
+
+type discordMessage struct {
+	Embeds []discordEmbed `json:"embeds"`
+	// AllowedMentions keeps @everyone and role mentions in the text from
+	// pinging anybody.
+	AllowedMentions map[string][]string `json:"allowed_mentions"`
+}
+
+type discordEmbed struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Color       int            `json:"color,omitempty"`
+	Fields      []discordField `json:"fields,omitempty"`
+}
+
+type discordField struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"`
+}
+
+func (discordProvider) Render(n Notification) (any, error) {
+	embed := discordEmbed{Title: truncate(n.Title, maxDiscordTitleLength)}
+	if color, err := strconv.ParseInt(strings.TrimPrefix(n.Color, "#"), 16, 32); err == nil {
+		embed.Color = int(color)
+	}
+	for i, field := range n.Fields {
+		if i == maxDiscordFields {
+			break
+		}
+		embed.Fields = append(embed.Fields, discordField{
+			Name:   truncate(field.Title, maxDiscordFieldNameLength),
+			Value:  truncate(MarkdownFromMrkdwn(field.Value), maxDiscordFieldValueLength),
+			Inline: true,
+		})
+	}
+
+	// Webhooks cannot send link buttons, so buttons become a line of links.
+	description := MarkdownFromMrkdwn(n.Text)
+	if len(n.Buttons) > 0 {
+		links := make([]string, 0, len(n.Buttons))
+		for _, button := range n.Buttons {
+			links = append(links, fmt.Sprintf("[%s](%s)", button.Text, button.URL))
+		}
+		description = strings.TrimSpace(description + "\n\n" + strings.Join(links, " • "))
+	}
+	// The title and fields count towards the total embed length too.
+	budget := maxDiscordEmbedLength - len([]rune(embed.Title))
+	for _, field := range embed.Fields {
+		budget -= len([]rune(field.Name)) + len([]rune(field.Value))
+	}
+	if budget < 1 {
+		return nil, fmt.Errorf("embed title and fields exceed %d characters", maxDiscordEmbedLength)
+	}
+	embed.Description = truncate(description, min(budget, maxDiscordDescriptionLength))
+
+	return discordMessage{
+		Embeds:          []discordEmbed{embed},
+		AllowedMentions: map[string][]string{"parse": {}},
+	}, nil
+}
+
+// Send posts the embed; Discord answers 204 without a body.
+func (discordProvider) Send(client *http.Client, webhookURL string, payload []byte) error {
+	_, err := postWebhook(client, webhookURL, payload)
+	return err
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDiscordGolden(t *testing.T) {
+	payload, err := discordProvider{}.Render(testNotification())
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	assertGolden(t, "discord/notification", payload)
+}
+
+func TestDiscordLimits(t *testing.T) {
+	n := Notification{Title: strings.Repeat("t", 300), Text: strings.Repeat("d", 5000)}
+	for i := 0; i < 30; i++ {
+		n.Fields = append(n.Fields, Field{Title: "Field", Value: strings.Repeat("v", 100)})
+	}
+	rendered, err := discordProvider{}.Render(n)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	embed := rendered.(discordMessage).Embeds[0]
+	if got := len([]rune(embed.Title)); got != maxDiscordTitleLength {
+		t.Errorf("Expected a %d character title, got %d", maxDiscordTitleLength, got)
+	}
+	if len(embed.Fields) != maxDiscordFields {
+		t.Errorf("Expected %d fields, got %d", maxDiscordFields, len(embed.Fields))
+	}
+	total := len([]rune(embed.Title)) + len([]rune(embed.Description))
+	for _, field := range embed.Fields {
+		total += len([]rune(field.Name)) + len([]rune(field.Value))
+	}
+	if total > maxDiscordEmbedLength {
+		t.Errorf("Expected the embed to stay within %d characters, got %d", maxDiscordEmbedLength, total)
+	}
+}
+
+func TestDiscordSend(t *testing.T) {
+	server, payloads := webhookServer(t, http.StatusNoContent, "")
+	if err := (discordProvider{}).Send(http.DefaultClient, server.URL, []byte(`{"embeds":[]}`)); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(*payloads) != 1 {
+		t.Errorf("Expected 1 webhook call, got %d", len(*payloads))
+	}
+
+	limited, _ := webhookServer(t, http.StatusTooManyRequests, `{"message": "You are being rate limited.", "retry_after": 1.5}`)
+	if err := (discordProvider{}).Send(http.DefaultClient, limited.URL, []byte(`{}`)); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Expected a rate limit error, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// PostEphemeral shows message to user in channel only. The message is not
+// stored, so it cannot be updated or reacted to later.
+func (w *webAPI) PostEphemeral(channel string, user string, message slack.Message) error {
+	message.Channel = channel
+	return w.callJSON("chat.postEphemeral", struct {
+		slack.Message
+		User string `json:"user"`
+	}{message, user}, nil)
+}
+
+// ephemeralError explains the postEphemeral errors that come from channel
+// membership rather than from the message itself.
+func ephemeralError(err error, channel string, user string) error {
+	switch {
+	case IsSlackError(err, "user_not_in_channel"):
+		return fmt.Errorf("user %s is not a member of channel %s; ephemeral messages can only be shown to channel members", user, channel)
+	case IsSlackError(err, "not_in_channel", "channel_not_found"):
+		return fmt.Errorf("the app cannot post to channel %s; invite it to the channel first: %v", channel, err)
+	}
+	return fmt.Errorf("error while sending ephemeral message to slack: %v", err)
+}
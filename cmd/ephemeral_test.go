@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+func TestPostEphemeral(t *testing.T) {
+	var body map[string]any
+	api := newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postEphemeral" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"ok":true,"message_ts":"1700000000.000100"}`))
+	})
+
+	err := api.PostEphemeral("C0123", "U0MONA", slack.Message{Text: "Deploy started"})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if body["channel"] != "C0123" || body["user"] != "U0MONA" || body["text"] != "Deploy started" {
+		t.Errorf("Unexpected request body %v", body)
+	}
+}
+
+func TestEphemeralError(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{code: "user_not_in_channel", expected: "user mona is not a member of channel C0123"},
+		{code: "not_in_channel", expected: "invite it to the channel first"},
+		{code: "invalid_blocks", expected: "error while sending ephemeral message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			api := newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"ok":false,"error":"` + tt.code + `"}`))
+			})
+			err := ephemeralError(api.PostEphemeral("C0123", "U0MONA", slack.Message{}), "C0123", "mona")
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestInitializeAppRejectsScheduledEphemeral(t *testing.T) {
+	SetupTestEnvironment()
+	defer CleanupTestEnvironment()
+	t.Setenv("INPUT_POST_AT", "+1h")
+	t.Setenv("INPUT_EPHEMERAL_USER", "U0MONA")
+
+	if err := initializeApp(); err == nil || !strings.Contains(err.Error(), "cannot be scheduled") {
+		t.Errorf("Expected scheduled ephemeral message to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMail is a message received by the fake SMTP server.
+type fakeMail struct {
+	From string
+	To   []string
+	Data string
+	// User is the authenticated user and TLS whether STARTTLS was used
+	// before the message was sent.
+	User string
+	TLS  bool
+}
+
+// fakeSMTP is an in-process SMTP server that understands STARTTLS and AUTH
+// PLAIN, which is all smtpMailer uses.
+type fakeSMTP struct {
+	listener net.Listener
+	t        *testing.T
+	tls      *tls.Config
+	// ClientTLS trusts the certificate of the server.
+	ClientTLS *tls.Config
+	// NoSTARTTLS makes the server leave STARTTLS out of its extensions.
+	NoSTARTTLS bool
+
+	mu   sync.Mutex
+	mail []fakeMail
+}
+
+// newFakeSMTP starts a fake SMTP server on 127.0.0.1 that is closed with
+// the test.
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	cert, pool := testCertificate(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected to listen: %v", err)
+	}
+	f := &fakeSMTP{
+		listener:  listener,
+		t:         t,
+		tls:       &tls.Config{Certificates: []tls.Certificate{cert}},
+		ClientTLS: &tls.Config{RootCAs: pool},
+	}
+	t.Cleanup(func() { listener.Close() })
+	go f.accept()
+	return f
+}
+
+// Port returns the port the server listens on.
+func (f *fakeSMTP) Port() int {
+	return f.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Mail returns the received messages.
+func (f *fakeSMTP) Mail() []fakeMail {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]fakeMail{}, f.mail...)
+}
+
+func (f *fakeSMTP) accept() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.session(conn)
+	}
+}
+
+func (f *fakeSMTP) session(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+
+	var mail fakeMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			extensions := []string{"fake"}
+			if !mail.TLS && !f.NoSTARTTLS {
+				extensions = append(extensions, "STARTTLS")
+			}
+			if mail.TLS {
+				extensions = append(extensions, "AUTH PLAIN")
+			}
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				tp.PrintfLine("250%s%s", separator, extension)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, f.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, tp = tlsConn, textproto.NewConn(tlsConn)
+			mail = fakeMail{TLS: true}
+		case "AUTH":
+			_, response, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(response)
+			parts := strings.Split(string(decoded), "\x00")
+			if len(parts) != 3 {
+				tp.PrintfLine("535 invalid credentials")
+				continue
+			}
+			mail.User = parts[1]
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			mail.From = fakeAddress(arg)
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			mail.To = append(mail.To, fakeAddress(arg))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			f.mu.Lock()
+			f.mail = append(f.mail, mail)
+			f.mu.Unlock()
+			mail = fakeMail{TLS: mail.TLS, User: mail.User}
+			tp.PrintfLine("250 queued")
+		case "RSET", "NOOP":
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 unknown command")
+		}
+	}
+}
+
+// fakeAddress returns the address in a MAIL FROM:<...> or RCPT TO:<...>
+// argument.
+func fakeAddress(arg string) string {
+	_, address, _ := strings.Cut(arg, "<")
+	address, _, _ = strings.Cut(address, ">")
+	return address
+}
+
+// testCertificate creates a self-signed certificate for 127.0.0.1 and a
+// pool that trusts it.
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Expected to generate a key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Expected to create a certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Expected to parse the certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// smtpEnv returns the inputs that point the email fallback at f.
+func (f *fakeSMTP) smtpEnv(to string) map[string]string {
+	return map[string]string{
+		"INPUT_FALLBACK_EMAIL_TO": to,
+		"INPUT_SMTP_HOST":         "127.0.0.1",
+		"INPUT_SMTP_PORT":         strconv.Itoa(f.Port()),
+		"INPUT_SMTP_USERNAME":     "ci",
+		"INPUT_SMTP_PASSWORD":     "secret",
+		"INPUT_SMTP_FROM":         "ci@example.com",
+	}
+}
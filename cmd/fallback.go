@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"html"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultSMTPPort is the mail submission port, which expects STARTTLS.
+const defaultSMTPPort = 587
+
+// Delivery channels reported in the delivered_via output.
+const (
+	DeliveredViaSlack = "slack"
+	DeliveredViaEmail = "email"
+)
+
+// smtpMailer submits mail over SMTP. The connection is always upgraded with
+// STARTTLS before credentials or content are sent.
+type smtpMailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	// TLSConfig overrides the TLS settings, for example to trust a test
+	// certificate.
+	TLSConfig *tls.Config
+	Timeout   time.Duration
+}
+
+func newSMTPMailer(e *Environment) smtpMailer {
+	port := e.Fallback.SMTPPort
+	if port == 0 {
+		port = defaultSMTPPort
+	}
+	return smtpMailer{
+		Host:     e.Fallback.SMTPHost,
+		Port:     port,
+		Username: e.Fallback.SMTPUsername,
+		Password: e.Fallback.SMTPPassword,
+		Timeout:  30 * time.Second,
+	}
+}
+
+// Send delivers message, a complete RFC 5322 message, from from to every
+// recipient in to.
+func (m smtpMailer) Send(from string, to []string, message []byte) error {
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	conn, err := net.DialTimeout("tcp", addr, m.Timeout)
+	if err != nil {
+		return fmt.Errorf("error connecting to %s: %v", addr, err)
+	}
+	if m.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(m.Timeout))
+	}
+	c, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error connecting to %s: %v", addr, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		return fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+	}
+	config := &tls.Config{ServerName: m.Host}
+	if m.TLSConfig != nil {
+		config = m.TLSConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = m.Host
+		}
+	}
+	if err := c.StartTLS(config); err != nil {
+		return fmt.Errorf("error starting TLS with %s: %v", addr, err)
+	}
+	if m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("error authenticating with %s: %v", addr, err)
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("error while sending email: %v", err)
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("error while sending email to %s: %v", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("error while sending email: %v", err)
+	}
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("error while sending email: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error while sending email: %v", err)
+	}
+	return c.Quit()
+}
+
+// BuildEmail renders n as a multipart/alternative message with a plain
+// text and an HTML part. Line breaks in the headers are rejected, as they
+// would let the values add headers of their own.
+func BuildEmail(from string, to []string, n Notification, date time.Time) ([]byte, error) {
+	for _, value := range append([]string{from, n.Title}, to...) {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, fmt.Errorf("invalid email header value %q: line breaks are not allowed", value)
+		}
+	}
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", emailText(n)},
+		{"text/html; charset=utf-8", emailHTML(n)},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	headers := []struct{ name, value string }{
+		{"From", from},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", n.Title)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()})},
+	}
+	for _, h := range headers {
+		fmt.Fprintf(&message, "%s: %s\r\n", h.name, h.value)
+	}
+	message.WriteString("\r\n")
+	message.Write(body.Bytes())
+	return message.Bytes(), nil
+}
+
+func emailText(n Notification) string {
+	var b strings.Builder
+	b.WriteString(n.Title + "\n\n")
+	if n.Text != "" {
+		b.WriteString(MarkdownFromMrkdwn(n.Text) + "\n\n")
+	}
+	for _, field := range n.Fields {
+		fmt.Fprintf(&b, "%s: %s\n", field.Title, MarkdownFromMrkdwn(field.Value))
+	}
+	for _, button := range n.Buttons {
+		fmt.Fprintf(&b, "%s: %s\n", button.Text, button.URL)
+	}
+	return strings.TrimSpace(b.String()) + "\n"
+}
+
+func emailHTML(n Notification) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><body>\n")
+	if n.Color != "" {
+		fmt.Fprintf(&b, `<div style="border-left: 4px solid %s; padding-left: 12px">`+"\n", html.EscapeString(n.Color))
+	} else {
+		b.WriteString("<div>\n")
+	}
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(n.Title))
+	if n.Text != "" {
+		fmt.Fprintf(&b, "<p>%s</p>\n", HTMLFromMrkdwn(n.Text))
+	}
+	if len(n.Fields) > 0 {
+		b.WriteString("<table>\n")
+		for _, field := range n.Fields {
+			fmt.Fprintf(&b, "<tr><th align=\"left\">%s</th><td>%s</td></tr>\n", html.EscapeString(field.Title), HTMLFromMrkdwn(field.Value))
+		}
+		b.WriteString("</table>\n")
+	}
+	if len(n.Buttons) > 0 {
+		links := make([]string, 0, len(n.Buttons))
+		for _, button := range n.Buttons {
+			links = append(links, fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(button.URL), html.EscapeString(button.Text)))
+		}
+		fmt.Fprintf(&b, "<p>%s</p>\n", strings.Join(links, " &bull; "))
+	}
+	b.WriteString("</div>\n</body></html>\n")
+	return b.String()
+}
+
+// PartialDeliveryError is returned when sending failed after the message
+// already reached some channels.
+type PartialDeliveryError struct {
+	// Channels got the message before the failure.
+	Channels []string
+	Err      error
+}
+
+func (e *PartialDeliveryError) Error() string {
+	return fmt.Sprintf("%v (already sent to %s)", e.Err, strings.Join(e.Channels, ", "))
+}
+
+func (e *PartialDeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// fallsBackToEmail reports whether err leaves Slack unreachable and a
+// fallback address is configured.
+func (e *Environment) fallsBackToEmail(err error) bool {
+	return len(e.Fallback.EmailTo) > 0 && IsTransient(err)
+}
+
+// emailFallback sends the message by email when posting to Slack failed
+// with a transient error before any channel got it, and a fallback address
+// is configured. It returns sendErr unchanged when no fallback applies.
+func emailFallback(sendErr error, n Notification, mailer smtpMailer) error {
+	if !envVar.fallsBackToEmail(sendErr) {
+		return sendErr
+	}
+	var partial *PartialDeliveryError
+	if errors.As(sendErr, &partial) {
+		logger.Warnf("not sending the message by email, it was already sent to %s", strings.Join(partial.Channels, ", "))
+		return sendErr
+	}
+	logger.Warnf("could not post to slack, sending the message by email instead: %v", sendErr)
+
+	message, err := BuildEmail(envVar.Fallback.SMTPFrom, envVar.Fallback.EmailTo, n, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w; error while building fallback email: %v", sendErr, err)
+	}
+	if err := mailer.Send(envVar.Fallback.SMTPFrom, envVar.Fallback.EmailTo, message); err != nil {
+		return fmt.Errorf("%w; fallback email failed: %v", sendErr, err)
+	}
+	logger.Infof("sent the message by email to %s", strings.Join(envVar.Fallback.EmailTo, ", "))
+	return setOutput("delivered_via", DeliveredViaEmail)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildEmail(t *testing.T) {
+	n := testNotification()
+	content, err := BuildEmail("ci@example.com", []string{"ops@example.com", "dev@example.com"}, n, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	message, err := mail.ReadMessage(strings.NewReader(string(content)))
+	if err != nil {
+		t.Fatalf("Expected a valid message, got %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	if err != nil || subject != n.Title {
+		t.Errorf("Expected subject %q, got %q (%v)", n.Title, subject, err)
+	}
+	if to := message.Header.Get("To"); to != "ops@example.com, dev@example.com" {
+		t.Errorf("Unexpected To header %q", to)
+	}
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Expected multipart/alternative, got %q (%v)", mediaType, err)
+	}
+
+	parts := map[string]string{}
+	reader := multipart.NewReader(message.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Expected valid parts, got %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = strings.ReplaceAll(string(body), "\r\n", "\n")
+	}
+
+	plain, html := parts["text/plain"], parts["text/html"]
+	if !strings.HasPrefix(plain, n.Title+"\n\n") || !strings.Contains(plain, n.Fields[0].Title+": ") {
+		t.Errorf("Unexpected text part %q", plain)
+	}
+	if !strings.Contains(plain, n.Buttons[0].URL) {
+		t.Errorf("Expected the text part to list the buttons, got %q", plain)
+	}
+	if !strings.Contains(html, "<h2>"+n.Title+"</h2>") || !strings.Contains(html, "<b>") {
+		t.Errorf("Unexpected HTML part %q", html)
+	}
+	if !strings.Contains(html, `href="`+n.Buttons[0].URL+`"`) {
+		t.Errorf("Expected the HTML part to link the buttons, got %q", html)
+	}
+}
+
+func TestBuildEmailRejectsLineBreaks(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	n := testNotification()
+	if _, err := BuildEmail("ci@example.com\r\nBcc: all@example.com", []string{"ops@example.com"}, n, date); err == nil {
+		t.Error("Expected an error for a line break in From")
+	}
+	if _, err := BuildEmail("ci@example.com", []string{"ops@example.com\nBcc: all@example.com"}, n, date); err == nil {
+		t.Error("Expected an error for a line break in To")
+	}
+	n.Title = "Deploy failed\r\nBcc: all@example.com"
+	if _, err := BuildEmail("ci@example.com", []string{"ops@example.com"}, n, date); err == nil {
+		t.Error("Expected an error for a line break in the subject")
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	t.Run("STARTTLS and auth", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		mailer := smtpMailer{Host: "127.0.0.1", Port: server.Port(), Username: "ci", Password: "secret", TLSConfig: server.ClientTLS, Timeout: 5 * time.Second}
+		if err := mailer.Send("ci@example.com", []string{"ops@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n")); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		received := server.Mail()
+		if len(received) != 1 {
+			t.Fatalf("Expected 1 message, got %d", len(received))
+		}
+		got := received[0]
+		if !got.TLS || got.User != "ci" || got.From != "ci@example.com" || len(got.To) != 1 || got.To[0] != "ops@example.com" {
+			t.Errorf("Unexpected message %+v", got)
+		}
+		if !strings.Contains(got.Data, "hello") {
+			t.Errorf("Unexpected data %q", got.Data)
+		}
+	})
+
+	t.Run("STARTTLS required", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		server.NoSTARTTLS = true
+		mailer := smtpMailer{Host: "127.0.0.1", Port: server.Port(), Username: "ci", Password: "secret", TLSConfig: server.ClientTLS, Timeout: 5 * time.Second}
+		err := mailer.Send("ci@example.com", []string{"ops@example.com"}, []byte("Subject: test\r\n\r\nhello\r\n"))
+		if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+			t.Fatalf("Expected a STARTTLS error, got %v", err)
+		}
+		if len(server.Mail()) != 0 {
+			t.Error("Expected nothing to be sent without TLS")
+		}
+	})
+
+	t.Run("Untrusted certificate", func(t *testing.T) {
+		server := newFakeSMTP(t)
+		mailer := smtpMailer{Host: "127.0.0.1", Port: server.Port(), Timeout: 5 * time.Second}
+		if err := mailer.Send("ci@example.com", []string{"ops@example.com"}, []byte("\r\n")); err == nil {
+			t.Fatal("Expected the self-signed certificate to be rejected")
+		}
+	})
+}
+
+func TestEmailFallback(t *testing.T) {
+	noRetrySleep(t)
+	inputs := func(server *fakeSMTP) map[string]string {
+		env := server.smtpEnv("ops@example.com")
+		env["INPUT_TITLE"] = "Deploy failed"
+		env["INPUT_TEXT"] = "Version *1.4.0* could not be deployed"
+		env["INPUT_SLACK_CHANNEL"] = "general"
+		env["INPUT_RETRIES"] = "1"
+		return env
+	}
+
+	t.Run("Transient error", func(t *testing.T) {
+		fake, server := newFakeSlack(t), newFakeSMTP(t)
+		fake.failNext("chat.postMessage", "internal_error")
+		fake.failNext("chat.postMessage", "service_unavailable")
+		outputs, err := runWithFakeSlack(t, fake, inputs(server), withSMTPTLSConfig(server.ClientTLS))
+		if err != nil {
+			t.Fatalf("Expected the fallback to succeed, got %v", err)
+		}
+		if !strings.Contains(outputs, "delivered_via=email\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+		received := server.Mail()
+		if len(received) != 1 {
+			t.Fatalf("Expected 1 email, got %d", len(received))
+		}
+		if !received[0].TLS || !strings.Contains(received[0].Data, "Subject: Deploy failed") {
+			t.Errorf("Unexpected email %+v", received[0])
+		}
+	})
+
+	t.Run("Permanent error", func(t *testing.T) {
+		fake, server := newFakeSlack(t), newFakeSMTP(t)
+		fake.failNext("chat.postMessage", "channel_not_found")
+		if _, err := runWithFakeSlack(t, fake, inputs(server), withSMTPTLSConfig(server.ClientTLS)); err == nil {
+			t.Fatal("Expected the Slack error")
+		}
+		if len(server.Mail()) != 0 {
+			t.Error("Expected no email for a permanent error")
+		}
+	})
+
+	t.Run("Partial delivery", func(t *testing.T) {
+		fake, server := newFakeSlack(t), newFakeSMTP(t)
+		fake.addChannel("C0OPS", "ops")
+		fake.failNext("chat.postMessage", "")
+		fake.failNext("chat.postMessage", "internal_error")
+		fake.failNext("chat.postMessage", "internal_error")
+		env := inputs(server)
+		env["INPUT_SLACK_CHANNEL"] = "C0GENERAL,C0OPS"
+		_, err := runWithFakeSlack(t, fake, env, withSMTPTLSConfig(server.ClientTLS))
+		if err == nil || !IsSlackError(err, "internal_error") || !strings.Contains(err.Error(), "already sent to C0GENERAL") {
+			t.Fatalf("Expected the Slack error, got %v", err)
+		}
+		if len(server.Mail()) != 0 {
+			t.Error("Expected no email once a channel got the message")
+		}
+	})
+
+	t.Run("Slack unreachable", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		closed := "http://" + listener.Addr().String() + "/api"
+		listener.Close()
+
+		server := newFakeSMTP(t)
+		outputs := filepath.Join(t.TempDir(), "outputs")
+		t.Setenv("GITHUB_OUTPUT", outputs)
+		t.Setenv("INPUT_SLACK_TOKEN", fakeSlackToken)
+		for key, value := range inputs(server) {
+			t.Setenv(key, value)
+		}
+		// Through execute, as main runs it, so that the failing token check
+		// comes first.
+		if err := execute(nil, withSlackAPIURL(closed), withSMTPTLSConfig(server.ClientTLS)); err != nil {
+			t.Fatalf("Expected the fallback to succeed, got %v", err)
+		}
+		if len(server.Mail()) != 1 {
+			t.Errorf("Expected 1 email, got %d", len(server.Mail()))
+		}
+		content, _ := os.ReadFile(outputs)
+		if !strings.Contains(string(content), "delivered_via=email\n") {
+			t.Errorf("Unexpected outputs %q", content)
+		}
+	})
+
+	t.Run("Email fails too", func(t *testing.T) {
+		fake, server := newFakeSlack(t), newFakeSMTP(t)
+		fake.failNext("chat.postMessage", "internal_error")
+		fake.failNext("chat.postMessage", "internal_error")
+		_, err := runWithFakeSlack(t, fake, inputs(server))
+		if err == nil || !IsSlackError(err, "internal_error") || !strings.Contains(err.Error(), "fallback email failed") {
+			t.Fatalf("Expected both errors, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// UploadFileWithContent uploads content as a file and shares it in the
+// channel, or thread, of messageRef. It uses the external upload flow that
+// replaces the retired files.upload method.
+func (w *webAPI) UploadFileWithContent(fileType string, fileName string, title string, content string, messageRef slack.MessageRef) error {
+	if fileName == "" {
+		fileName = "content"
+		if fileType != "" {
+			fileName += "." + fileType
+		}
+	}
+	var upload struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	err := w.call("files.getUploadURLExternal", url.Values{
+		"filename": {fileName},
+		"length":   {fmt.Sprint(len(content))},
+	}, &upload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := w.httpClient.Post(upload.UploadURL, "application/octet-stream", bytes.NewReader([]byte(content)))
+	if err != nil {
+		return fmt.Errorf("error uploading file %s: %v", fileName, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error uploading file %s: unexpected status code: %d", fileName, resp.StatusCode)
+	}
+
+	type file struct {
+		ID    string `json:"id"`
+		Title string `json:"title,omitempty"`
+	}
+	return w.callJSON("files.completeUploadExternal", struct {
+		Files    []file `json:"files"`
+		Channel  string `json:"channel_id,omitempty"`
+		ThreadTS string `json:"thread_ts,omitempty"`
+	}{[]file{{upload.FileID, title}}, messageRef.Channel, messageRef.Timestamp}, nil)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// appendStepSummary appends markdown to the job summary file provided by
+// GitHub Actions. It is a no-op when not running under Actions.
+func appendStepSummary(markdown string) error {
+	return appendToEnvFile("GITHUB_STEP_SUMMARY", markdown)
+}
+
+// setOutput sets a step output through the GitHub Actions output file.
+// Multi-line values use the delimiter syntax.
+func setOutput(name string, value string) error {
+	if !strings.Contains(value, "\n") {
+		return appendToEnvFile("GITHUB_OUTPUT", fmt.Sprintf("%s=%s\n", name, value))
+	}
+	delimiter := fmt.Sprintf("ghadelimiter_%d", time.Now().UnixNano())
+	return appendToEnvFile("GITHUB_OUTPUT", fmt.Sprintf("%s<<%s\n%s\n%s\n", name, delimiter, value, delimiter))
+}
+
+// appendToEnvFile appends content to the file named by the given environment
+// variable, as used by the GitHub Actions file commands.
+func appendToEnvFile(name string, content string) error {
+	path := os.Getenv(name)
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", name, err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		return fmt.Errorf("error writing %s: %v", name, err)
+	}
+	return nil
+}
+
+// GitHubContext holds the default environment variables GitHub Actions sets
+// for every step.
+type GitHubContext struct {
+	Repository string `env:"GITHUB_REPOSITORY"`
+	Ref        string `env:"GITHUB_REF"`
+	RefName    string `env:"GITHUB_REF_NAME"`
+	SHA        string `env:"GITHUB_SHA"`
+	Actor      string `env:"GITHUB_ACTOR"`
+	EventName  string `env:"GITHUB_EVENT_NAME"`
+	EventPath  string `env:"GITHUB_EVENT_PATH"`
+	Workflow   string `env:"GITHUB_WORKFLOW"`
+	Job        string `env:"GITHUB_JOB"`
+	RunID      string `env:"GITHUB_RUN_ID"`
+	ServerURL  string `env:"GITHUB_SERVER_URL"`
+	Workspace  string `env:"GITHUB_WORKSPACE"`
+}
+
+// RunURL returns the link to the workflow run, or an empty string outside of
+// GitHub Actions.
+func (g GitHubContext) RunURL() string {
+	if g.ServerURL == "" || g.Repository == "" || g.RunID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", g.ServerURL, g.Repository, g.RunID)
+}
+
+// ChangedFiles lists the files changed by the triggering event. Push events
+// carry the list in the event payload; for pull requests the files are taken
+// from git in the workspace, which requires the base commit to be fetched.
+func (g GitHubContext) ChangedFiles() ([]string, error) {
+	if g.EventPath == "" {
+		return nil, fmt.Errorf("GITHUB_EVENT_PATH is not set")
+	}
+	content, err := os.ReadFile(g.EventPath)
+	if err != nil {
+		return nil, err
+	}
+	var event struct {
+		Commits []struct {
+			Added    []string `json:"added"`
+			Removed  []string `json:"removed"`
+			Modified []string `json:"modified"`
+		} `json:"commits"`
+		PullRequest *struct {
+			Base struct {
+				SHA string `json:"sha"`
+			} `json:"base"`
+			Head struct {
+				SHA string `json:"sha"`
+			} `json:"head"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(content, &event); err != nil {
+		return nil, fmt.Errorf("invalid event payload: %v", err)
+	}
+
+	if event.PullRequest != nil {
+		// -z ends every name with NUL and leaves names with spaces or
+		// other unusual characters unquoted.
+		cmd := exec.Command("git", "diff", "--name-only", "-z", event.PullRequest.Base.SHA, event.PullRequest.Head.SHA)
+		cmd.Dir = g.Workspace
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("error running git diff: %v", err)
+		}
+		names := strings.TrimSuffix(string(out), "\x00")
+		if names == "" {
+			return nil, nil
+		}
+		return strings.Split(names, "\x00"), nil
+	}
+
+	seen := map[string]bool{}
+	var files []string
+	for _, commit := range event.Commits {
+		for _, list := range [][]string{commit.Added, commit.Removed, commit.Modified} {
+			for _, file := range list {
+				if !seen[file] {
+					seen[file] = true
+					files = append(files, file)
+				}
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// update rewrites the golden files instead of comparing against them:
+//
+//	go test ./cmd -run Golden -update
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/")
+
+// canonicalJSON serializes v as indented JSON with object keys sorted, so
+// that golden files do not depend on struct field order. Slack mentions such
+// as <@U123> are kept readable rather than HTML escaped.
+func canonicalJSON(v any) ([]byte, error) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(content, &generic); err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(generic); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// assertGolden compares v, serialized with canonicalJSON, with the golden
+// file testdata/<name>.json. With -update the file is written instead.
+func assertGolden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := canonicalJSON(v)
+	if err != nil {
+		t.Fatalf("Failed to serialize %s: %v", name, err)
+	}
+	path := filepath.Join("testdata", name+".json")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Missing golden file %s, run go test -run %s -update: %v", path, t.Name(), err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Output differs from %s, run go test -run %s -update if the change is intended.\nGot:\n%s\nWant:\n%s", path, t.Name(), got, want)
+	}
+}
+
+func TestSlackGolden(t *testing.T) {
+	manyFields := Fields{}
+	for _, name := range []string{"Environment", "Version", "Commit", "Actor", "Region", "Cluster", "Replicas", "Duration", "Tests", "Coverage", "Image", "Digest"} {
+		manyFields = append(manyFields, Field{Title: name, Value: "value of " + name})
+	}
+
+	tests := []struct {
+		name  string
+		setup func()
+	}{
+		{name: "basic", setup: func() {}},
+		{name: "fields", setup: func() {
+			envVar.Input.Fields = manyFields
+		}},
+		{name: "buttons", setup: func() {
+			envVar.Input.Buttons = Buttons{
+				{Text: "View run", URL: "https://github.com/pal-paul/message-slack/actions/runs/42"},
+				{Text: "Changelog", URL: "https://github.com/pal-paul/message-slack/releases"},
+			}
+		}},
+		{name: "mentions", setup: func() {
+			envVar.Input.Mentions = "<@U0MONA> <!subteam^S0ONCALL>"
+		}},
+		{name: "unicode", setup: func() {
+			envVar.Input.Title = "Déploiement terminé 🚀"
+			envVar.Input.Text = "Étape *1/3* — ✅ «prod»"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envVar = Environment{}
+			envVar.Input.Title = "Deploy finished"
+			envVar.Input.Text = "Version *1.4.0* is live in `production`"
+			tt.setup()
+			assertGolden(t, "slack/"+tt.name, buildMessage("C0DEPLOYS"))
+		})
+	}
+
+	t.Run("metadata", func(t *testing.T) {
+		message := SlackMessageBuilder("Deploy finished", "Version *1.4.0* is live", "C0DEPLOYS")
+		assertGolden(t, "slack/metadata", outgoingMessage{message, &MessageMetadata{
+			EventType:    defaultMetadataEventType,
+			EventPayload: map[string]any{"repository": "pal-paul/message-slack", "sha": "abc123", "run_id": "42", "status": "success"},
+		}})
+	})
+
+	t.Run("keyed", func(t *testing.T) {
+		client := &fakePublisher{}
+		store := newFileStore(filepath.Join(t.TempDir(), "messages.json"))
+		message := SlackMessageBuilder("Deploy abc123", "Rolling out", "C0DEPLOYS")
+		if _, _, err := publish(client, client, store, "deploy abc123", "C0DEPLOYS", message); err != nil {
+			t.Fatal(err)
+		}
+		assertGolden(t, "slack/keyed", client.posted[0])
+	})
+}
+
+func TestAlertGolden(t *testing.T) {
+	firing := testAlertNotification(AlertFiring)
+	firing.Alerts[1].Status = AlertResolved
+	firing.Alerts[1].EndsAt = firing.Alerts[1].StartsAt.Add(time.Hour)
+	assertGolden(t, "slack/alert_firing", AlertMessage(firing, "C0ALERTS"))
+	assertGolden(t, "slack/alert_resolved", AlertMessage(testAlertNotification(AlertResolved), "C0ALERTS"))
+}
+
+func TestDigestGolden(t *testing.T) {
+	state := DigestState{Key: "build 42", Version: 3, Total: 4, Entries: []DigestEntry{
+		{Job: "lint", Status: "success", Duration: "42s"},
+		{Job: "test (linux)", Status: "failure", Duration: "3m10s"},
+		{Job: "test (macos)", Status: "success"},
+	}}
+	assertGolden(t, "slack/digest", DigestMessage("Build 42", "Commit `abc123` on main", state, "C0BUILDS"))
+}
+
+func TestHookGolden(t *testing.T) {
+	channel, n, err := testHook().Message("sentry", []byte(sentryPayload), "alerts")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	assertGolden(t, "slack/hook", notificationMessage(channel, n))
+}
+
+func TestSilencedGolden(t *testing.T) {
+	envVar = Environment{}
+	envVar.Input.Title = "Deploy failed"
+	envVar.Input.Text = "<!here> version *1.4.0* failed, <@U0MONA> please look"
+	envVar.Input.Mentions = "<!subteam^S0ONCALL|@oncall>"
+	envVar.Input.Fields = Fields{{Title: "Owner", Value: "<@U0MONA>"}}
+	assertGolden(t, "slack/silenced", silenced(buildMessage("C0DEPLOYS")))
+}
+
+func TestRepeatGolden(t *testing.T) {
+	assertGolden(t, "slack/repeat", RepeatMessage(DedupeRecord{Channel: "C0ALERTS", TS: "1700000000.000001", Count: 3}))
+}
+
+// TestSlackGoldenFilesAreValidBlockKit keeps renderer changes from producing
+// payloads that Slack would reject.
+func TestSlackGoldenFilesAreValidBlockKit(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "slack", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("Expected Slack golden files in testdata/slack")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, problem := range ValidateBlockKit(content) {
+				t.Errorf("%s: %v", file, problem)
+			}
+		})
+	}
+}
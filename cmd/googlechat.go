@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// maxGoogleChatPayloadBytes is the largest message Google Chat accepts.
+const maxGoogleChatPayloadBytes = 32000
+
+// googleChatProvider posts cards to a Google Chat space webhook.
+type googleChatProvider struct{}
+
+type googleChatMessage struct {
+	// Text is the notification text shown for the card.
+	Text    string           `json:"text"`
+	CardsV2 []googleChatCard `json:"cardsV2"`
+}
+
+type googleChatCard struct {
+	CardID string `json:"cardId"`
+	Card   struct {
+		Header   googleChatHeader    `json:"header"`
+		Sections []googleChatSection `json:"sections"`
+	} `json:"card"`
+}
+
+type googleChatHeader struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle,omitempty"`
+}
+
+type googleChatSection struct {
+	Widgets []googleChatWidget `json:"widgets"`
+}
+
+type googleChatWidget struct {
+	TextParagraph *googleChatText          `json:"textParagraph,omitempty"`
+	DecoratedText *googleChatDecoratedText `json:"decoratedText,omitempty"`
+	ButtonList    *googleChatButtonList    `json:"buttonList,omitempty"`
+}
+
+type googleChatText struct {
+	Text string `json:"text"`
+}
+
+type googleChatDecoratedText struct {
+	TopLabel string `json:"topLabel"`
+	Text     string `json:"text"`
+	WrapText bool   `json:"wrapText"`
+}
+
+type googleChatButtonList struct {
+	Buttons []googleChatButton `json:"buttons"`
+}
+
+type googleChatButton struct {
+	Text    string `json:"text"`
+	OnClick struct {
+		OpenLink struct {
+			URL string `json:"url"`
+		} `json:"openLink"`
+	} `json:"onClick"`
+}
+
+func (googleChatProvider) Render(n Notification) (any, error) {
+	message := googleChatMessage{Text: n.Title}
+	card := googleChatCard{CardID: "message-slack"}
+	card.Card.Header = googleChatHeader{Title: n.Title, Subtitle: n.Status}
+
+	if n.Text != "" {
+		widget := googleChatWidget{TextParagraph: &googleChatText{Text: HTMLFromMrkdwn(n.Text)}}
+		card.Card.Sections = append(card.Card.Sections, googleChatSection{Widgets: []googleChatWidget{widget}})
+	}
+	if len(n.Fields) > 0 {
+		var section googleChatSection
+		for _, field := range n.Fields {
+			section.Widgets = append(section.Widgets, googleChatWidget{DecoratedText: &googleChatDecoratedText{
+				TopLabel: field.Title,
+				Text:     HTMLFromMrkdwn(field.Value),
+				WrapText: true,
+			}})
+		}
+		card.Card.Sections = append(card.Card.Sections, section)
+	}
+	if len(n.Buttons) > 0 {
+		list := &googleChatButtonList{}
+		for _, b := range n.Buttons {
+			button := googleChatButton{Text: b.Text}
+			button.OnClick.OpenLink.URL = b.URL
+			list.Buttons = append(list.Buttons, button)
+		}
+		card.Card.Sections = append(card.Card.Sections, googleChatSection{Widgets: []googleChatWidget{{ButtonList: list}}})
+	}
+	message.CardsV2 = []googleChatCard{card}
+
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxGoogleChatPayloadBytes {
+		return nil, fmt.Errorf("card is %d bytes, Google Chat accepts at most %d", len(payload), maxGoogleChatPayloadBytes)
+	}
+	return message, nil
+}
+
+// Send posts the card; Google Chat answers with the created message.
+func (googleChatProvider) Send(client *http.Client, webhookURL string, payload []byte) error {
+	_, err := postWebhook(client, webhookURL, payload)
+	return err
+}
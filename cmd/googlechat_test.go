@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGoogleChatGolden(t *testing.T) {
+	payload, err := googleChatProvider{}.Render(testNotification())
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	assertGolden(t, "googlechat/notification", payload)
+}
+
+func TestGoogleChatLimits(t *testing.T) {
+	if _, err := (googleChatProvider{}).Render(Notification{Title: "Deploy", Text: strings.Repeat("a", maxGoogleChatPayloadBytes)}); err == nil {
+		t.Error("Expected an error for a card over the size limit")
+	}
+}
+
+func TestGoogleChatSend(t *testing.T) {
+	server, payloads := webhookServer(t, http.StatusOK, `{"name": "spaces/AAA/messages/BBB"}`)
+	if err := (googleChatProvider{}).Send(http.DefaultClient, server.URL, []byte(`{"text":"Deploy"}`)); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(*payloads) != 1 || (*payloads)[0]["text"] != "Deploy" {
+		t.Errorf("Unexpected payloads %v", *payloads)
+	}
+}
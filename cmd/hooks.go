@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/tidwall/gjson"
+)
+
+// HookConfig turns the JSON webhooks of a tool into messages. Extract maps
+// names to gjson paths, or JSONPath expressions starting with $, into the
+// request body; the extracted values are available to the templates as
+// {{ .name }}.
+type HookConfig struct {
+	Extract map[string]string `yaml:"extract"`
+	// Channel is a template as well, so that hooks can route by content.
+	Channel string   `yaml:"channel"`
+	Title   string   `yaml:"title"`
+	Text    string   `yaml:"text"`
+	Fields  []Field  `yaml:"fields"`
+	Buttons []Button `yaml:"buttons"`
+	// Clients restricts the clients that may call the hook.
+	Clients stringList `yaml:"clients"`
+}
+
+// validate checks that the hook renders a message and that its templates
+// and paths parse, so that mistakes show up when the relay starts.
+func (h *HookConfig) validate(name string) error {
+	if h.Title == "" || h.Text == "" {
+		return fmt.Errorf("hook %s needs a title and text", name)
+	}
+	templates := map[string]string{"channel": h.Channel, "title": h.Title, "text": h.Text}
+	for _, field := range h.Fields {
+		templates["fields."+field.Title] = field.Value
+	}
+	for _, button := range h.Buttons {
+		templates["buttons."+button.Text] = button.URL
+	}
+	for part, text := range templates {
+		if _, err := template.New(part).Parse(text); err != nil {
+			return fmt.Errorf("hook %s: invalid template %s: %v", name, part, err)
+		}
+	}
+	for key, path := range h.Extract {
+		if path == "" {
+			return fmt.Errorf("hook %s: empty path for %s", name, key)
+		}
+		if _, err := parseJSONPath(path); err != nil {
+			return fmt.Errorf("hook %s: invalid path for %s: %v", name, key, err)
+		}
+	}
+	return nil
+}
+
+// allows reports whether client may call the hook.
+func (h *HookConfig) allows(client *RelayClient) bool {
+	if len(h.Clients) == 0 {
+		return true
+	}
+	for _, name := range h.Clients {
+		if name == client.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// extract returns the values the hook extracts from body. Paths that match
+// nothing yield an empty string, so templates can test for them.
+func (h *HookConfig) extract(body []byte) map[string]any {
+	values := make(map[string]any, len(h.Extract))
+	for name, path := range h.Extract {
+		result := gjson.GetBytes(body, gjsonPath(path))
+		if !result.Exists() {
+			values[name] = ""
+			continue
+		}
+		values[name] = escapeValue(result.Value())
+	}
+	return values
+}
+
+// slackEscaper escapes the characters Slack reads as control sequences.
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// escapeValue escapes the strings in a value from a webhook body, so that
+// the sender cannot ping with <!channel> or disguise links.
+func escapeValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return slackEscaper.Replace(v)
+	case []any:
+		escaped := make([]any, len(v))
+		for i, item := range v {
+			escaped[i] = escapeValue(item)
+		}
+		return escaped
+	case map[string]any:
+		escaped := make(map[string]any, len(v))
+		for key, item := range v {
+			escaped[key] = escapeValue(item)
+		}
+		return escaped
+	}
+	return value
+}
+
+// Message renders the message for a webhook body. It returns the channel
+// from the hook's channel template, or fallback when that is empty.
+func (h *HookConfig) Message(name string, body []byte, fallback string) (string, Notification, error) {
+	data := h.extract(body)
+	render := func(part string, text string) (string, error) {
+		return renderTemplate("hooks."+name+"."+part, text, data)
+	}
+
+	var n Notification
+	channel, err := render("channel", h.Channel)
+	if err != nil {
+		return "", n, err
+	}
+	if channel = strings.TrimSpace(channel); channel == "" {
+		channel = fallback
+	}
+	if n.Title, err = render("title", h.Title); err != nil {
+		return "", n, err
+	}
+	if n.Text, err = render("text", h.Text); err != nil {
+		return "", n, err
+	}
+	for _, field := range h.Fields {
+		if field.Value, err = render("fields."+field.Title, field.Value); err != nil {
+			return "", n, err
+		}
+		if field.Value != "" {
+			n.Fields = append(n.Fields, field)
+		}
+	}
+	for _, button := range h.Buttons {
+		if button.URL, err = render("buttons."+button.Text, button.URL); err != nil {
+			return "", n, err
+		}
+		if button.URL != "" {
+			n.Buttons = append(n.Buttons, button)
+		}
+	}
+	return channel, n, nil
+}
+
+// gjsonPath converts a JSONPath expression such as $.items[0]['name'] or
+// $.items[*].id to gjson syntax. Other paths are returned unchanged.
+func gjsonPath(path string) string {
+	converted, err := parseJSONPath(path)
+	if err != nil {
+		return path
+	}
+	return converted
+}
+
+// parseJSONPath converts a JSONPath expression to gjson syntax. Only child
+// names, indexes and wildcards are supported; recursive descent, filters,
+// scripts, slices and unions are rejected, as gjson has no equivalent that
+// selects the same values.
+func parseJSONPath(path string) (string, error) {
+	if !strings.HasPrefix(path, "$") {
+		return path, nil
+	}
+	rest := path[1:]
+	var parts []string
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ".."):
+			return "", fmt.Errorf("recursive descent (..) is not supported in %s", path)
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return "", fmt.Errorf("unterminated [ in %s", path)
+			}
+			index := rest[1:end]
+			rest = rest[end+1:]
+			switch {
+			case index == "*":
+				parts = append(parts, "#")
+			case strings.HasPrefix(index, "'") || strings.HasPrefix(index, `"`):
+				parts = append(parts, escapeGJSON(strings.Trim(index, `'"`)))
+			case strings.HasPrefix(index, "?") || strings.HasPrefix(index, "("):
+				return "", fmt.Errorf("filter and script expressions ([%s]) are not supported in %s", index, path)
+			case strings.ContainsAny(index, ":,"):
+				return "", fmt.Errorf("slices and unions ([%s]) are not supported in %s", index, path)
+			default:
+				parts = append(parts, index)
+			}
+		case strings.HasPrefix(rest, "."):
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if name := rest[:end]; name == "*" {
+				parts = append(parts, "#")
+			} else if name != "" {
+				parts = append(parts, escapeGJSON(name))
+			}
+			rest = rest[end:]
+		default:
+			return "", fmt.Errorf("expected . or [ after $ in %s", path)
+		}
+	}
+	if len(parts) == 0 {
+		return "@this", nil
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// gjsonSpecial are the characters with a meaning in gjson paths.
+var gjsonSpecial = strings.NewReplacer(".", `\.`, "*", `\*`, "?", `\?`, "|", `\|`, "#", `\#`, "@", `\@`)
+
+func escapeGJSON(name string) string {
+	return gjsonSpecial.Replace(name)
+}
+
+// handleHook transforms the webhook of a configured tool into a message.
+func (s *relayServer) handleHook(w http.ResponseWriter, r *http.Request) {
+	// Authenticate first, so that the names of the hooks are not revealed
+	// to unauthenticated callers.
+	body, client, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("name")
+	hook, ok := s.config.Hooks[name]
+	if !ok {
+		writeRelayError(w, http.StatusNotFound, fmt.Sprintf("unknown hook %s", name))
+		return
+	}
+	if !hook.allows(client) {
+		writeRelayError(w, http.StatusForbidden, fmt.Sprintf("client %s may not call hook %s", client.Name, name))
+		return
+	}
+	if !gjson.ValidBytes(body) {
+		writeRelayError(w, http.StatusBadRequest, "request body is not valid JSON")
+		return
+	}
+
+	channel, n, err := hook.Message(name, body, s.config.Channel)
+	switch {
+	case err != nil:
+		writeRelayError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	case n.Title == "" || n.Text == "":
+		writeRelayError(w, http.StatusUnprocessableEntity, fmt.Sprintf("hook %s rendered an empty title or text", name))
+		return
+	case channel == "":
+		writeRelayError(w, http.StatusUnprocessableEntity, fmt.Sprintf("hook %s has no channel", name))
+		return
+	case !client.allows(channel):
+		writeRelayError(w, http.StatusForbidden, fmt.Sprintf("client %s may not post to %s", client.Name, channel))
+		return
+	}
+
+	s.send(w, fmt.Sprintf("hook %s from client %s", name, client.Name), channel, n)
+}
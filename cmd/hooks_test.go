@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sentryPayload = `{
+  "action": "triggered",
+  "data": {
+    "event": {
+      "title": "ZeroDivisionError: division by zero",
+      "level": "fatal",
+      "web_url": "https://sentry.example.com/issues/1/",
+      "tags": [["environment", "production"], ["release", "1.4.0"]],
+      "user.email": "dev@example.com"
+    }
+  }
+}`
+
+func testHook() *HookConfig {
+	return &HookConfig{
+		Extract: map[string]string{
+			"title":       "data.event.title",
+			"level":       "$.data.event.level",
+			"url":         "$['data']['event']['web_url']",
+			"environment": "data.event.tags.0.1",
+			"user":        `$.data.event['user.email']`,
+			"missing":     "data.event.culprit",
+		},
+		Channel: `{{ if eq .level "fatal" }}general{{ end }}`,
+		Title:   "Sentry: {{ .title }}",
+		Text:    "*{{ .level }}* in {{ .environment }}{{ if .missing }} at {{ .missing }}{{ end }}",
+		Fields: []Field{
+			{Title: "User", Value: "{{ .user }}"},
+			{Title: "Culprit", Value: "{{ .missing }}"},
+		},
+		Buttons: []Button{{Text: "Open in Sentry", URL: "{{ .url }}"}},
+	}
+}
+
+func TestGJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"data.event.title", "data.event.title"},
+		{"$.data.event.title", "data.event.title"},
+		{"$.items[0].name", "items.0.name"},
+		{"$.items[*].id", "items.#.id"},
+		{"$['a.b'].c", `a\.b.c`},
+		{`$["x"]["y"]`, "x.y"},
+		{"$", "@this"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := gjsonPath(tt.path); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHookMessage(t *testing.T) {
+	hook := testHook()
+	if err := hook.validate("sentry"); err != nil {
+		t.Fatalf("Expected a valid hook, got %v", err)
+	}
+	channel, n, err := hook.Message("sentry", []byte(sentryPayload), "alerts")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if channel != "general" {
+		t.Errorf("Expected the channel template to route fatal events to general, got %s", channel)
+	}
+	if n.Title != "Sentry: ZeroDivisionError: division by zero" || n.Text != "*fatal* in production" {
+		t.Errorf("Unexpected title and text %q, %q", n.Title, n.Text)
+	}
+	if len(n.Fields) != 1 || n.Fields[0].Value != "dev@example.com" {
+		t.Errorf("Expected empty fields to be left out, got %+v", n.Fields)
+	}
+	if len(n.Buttons) != 1 || n.Buttons[0].URL != "https://sentry.example.com/issues/1/" {
+		t.Errorf("Unexpected buttons %+v", n.Buttons)
+	}
+
+	channel, _, _ = hook.Message("sentry", []byte(strings.Replace(sentryPayload, "fatal", "error", 1)), "alerts")
+	if channel != "alerts" {
+		t.Errorf("Expected other events to go to the default channel, got %s", channel)
+	}
+}
+
+func TestHookMessageEscapesValues(t *testing.T) {
+	hook := &HookConfig{
+		Extract: map[string]string{"title": "title", "tags": "tags"},
+		Title:   "{{ .title }}",
+		Text:    "{{ .title }} {{ index .tags 0 }}",
+	}
+	body := `{"title": "<!channel> Tom & Jerry", "tags": ["<https://evil.example.com|docs>"]}`
+	_, n, err := hook.Message("test", []byte(body), "alerts")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	expected := "&lt;!channel&gt; Tom &amp; Jerry &lt;https://evil.example.com|docs&gt;"
+	if n.Text != expected {
+		t.Errorf("Expected %q, got %q", expected, n.Text)
+	}
+}
+
+func TestHookValidate(t *testing.T) {
+	hook := testHook()
+	hook.Text = "{{ .level"
+	if err := hook.validate("sentry"); err == nil || !strings.Contains(err.Error(), "invalid template text") {
+		t.Errorf("Expected a template error, got %v", err)
+	}
+	if err := (&HookConfig{Title: "x"}).validate("empty"); err == nil {
+		t.Error("Expected a hook without text to be rejected")
+	}
+
+	for _, path := range []string{"$..name", "$.items[?(@.id > 1)].name", "$.items[(@.length-1)]", "$.items[0:2]", "$.items[0,1]", "$.items[0", "$name"} {
+		hook := testHook()
+		hook.Extract["bad"] = path
+		if err := hook.validate("sentry"); err == nil || !strings.Contains(err.Error(), "invalid path for bad") {
+			t.Errorf("Expected %s to be rejected, got %v", path, err)
+		}
+	}
+}
+
+func TestHookEndpoint(t *testing.T) {
+	config := testServerConfig()
+	config.Hooks = map[string]*HookConfig{"sentry": testHook()}
+	restricted := testHook()
+	restricted.Clients = stringList{"argo"}
+	config.Hooks["argo-only"] = restricted
+	server, fake := newTestRelay(t, config)
+	headers := map[string]string{headerAPIKey: "jenkins-key"}
+
+	status, response := relayPost(t, server.URL+"/hooks/sentry", sentryPayload, headers)
+	if status != http.StatusOK || response.Channel != "C0GENERAL" {
+		t.Fatalf("Expected the hook to post, got %d %+v", status, response)
+	}
+	if posted := fake.requestsFor("chat.postMessage"); len(posted) != 1 || !strings.Contains(posted[0].param("blocks"), "Sentry: ZeroDivisionError") {
+		t.Errorf("Unexpected posts %+v", posted)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{"Unknown hook", "/hooks/grafana", sentryPayload, headers, http.StatusNotFound},
+		{"Unauthenticated", "/hooks/sentry", sentryPayload, nil, http.StatusUnauthorized},
+		{"Unauthenticated unknown hook", "/hooks/grafana", sentryPayload, nil, http.StatusUnauthorized},
+		{"Client not allowed", "/hooks/argo-only", sentryPayload, headers, http.StatusForbidden},
+		{"Invalid JSON", "/hooks/sentry", "{", headers, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, response := relayPost(t, server.URL+tt.path, tt.body, tt.headers); status != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d %+v", tt.wantStatus, status, response)
+			}
+		})
+	}
+}
+
+func TestLoadServerConfigHooks(t *testing.T) {
+	content := `clients:
+  - name: sentry
+    api_key: key
+hooks:
+  sentry:
+    extract:
+      title: data.event.title
+    title: "{{ .title }}"
+    text: "{{ .title"
+`
+	path := filepath.Join(t.TempDir(), "relay.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadServerConfig(path); err == nil || !strings.Contains(err.Error(), "hook sentry") {
+		t.Errorf("Expected the broken hook template to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log levels, from the most verbose.
+const (
+	LevelDebug   = "debug"
+	LevelInfo    = "info"
+	LevelNotice  = "notice"
+	LevelWarning = "warning"
+	LevelError   = "error"
+)
+
+var logLevels = map[string]int{
+	LevelDebug:   0,
+	LevelInfo:    1,
+	LevelNotice:  2,
+	LevelWarning: 3,
+	LevelError:   4,
+}
+
+// Log formats. Under GitHub Actions the format is ignored and lines are
+// written as workflow commands.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Logger writes leveled log lines. Under GitHub Actions debug, notice,
+// warning and error lines become workflow commands, so that they are shown
+// as annotations; elsewhere lines are text or JSON.
+type Logger struct {
+	mu      sync.Mutex
+	out     io.Writer
+	level   int
+	json    bool
+	actions bool
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// NewLogger returns a logger writing lines of level and above to out.
+// RUNNER_DEBUG, set when a workflow is re-run with debug logging, enables
+// debug lines regardless of level.
+func NewLogger(out io.Writer, level string, format string, actions bool) (*Logger, error) {
+	if level == "" {
+		level = LevelInfo
+	}
+	rank, ok := logLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("invalid log level %q, expected debug, info, notice, warning or error", level)
+	}
+	if os.Getenv("RUNNER_DEBUG") == "1" {
+		rank = logLevels[LevelDebug]
+	}
+	switch format {
+	case "", LogFormatText, LogFormatJSON:
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+	return &Logger{out: out, level: rank, json: format == LogFormatJSON, actions: actions, now: time.Now}, nil
+}
+
+// runningInActions reports whether the binary runs as a GitHub Action.
+func runningInActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// newDefaultLogger returns the logger used until the inputs are read. Under
+// Actions workflow commands are read from stdout.
+func newDefaultLogger() *Logger {
+	if runningInActions() {
+		l, _ := NewLogger(os.Stdout, LevelInfo, LogFormatText, true)
+		return l
+	}
+	l, _ := NewLogger(os.Stderr, LevelInfo, LogFormatText, false)
+	return l
+}
+
+// logger is used for every log line of the action and the relay server.
+var logger = newDefaultLogger()
+
+// configureLogger replaces logger with one using level and format.
+func configureLogger(level string, format string) error {
+	out := io.Writer(os.Stderr)
+	if runningInActions() {
+		out = os.Stdout
+	}
+	l, err := NewLogger(out, level, format, runningInActions())
+	if err != nil {
+		return err
+	}
+	logger = l
+	return nil
+}
+
+func (l *Logger) Debugf(format string, args ...any)  { l.log(LevelDebug, format, args...) }
+func (l *Logger) Infof(format string, args ...any)   { l.log(LevelInfo, format, args...) }
+func (l *Logger) Noticef(format string, args ...any) { l.log(LevelNotice, format, args...) }
+func (l *Logger) Warnf(format string, args ...any)   { l.log(LevelWarning, format, args...) }
+func (l *Logger) Errorf(format string, args ...any)  { l.log(LevelError, format, args...) }
+
+// Fatal logs err and exits.
+func (l *Logger) Fatal(err error) {
+	l.Errorf("%v", err)
+	os.Exit(1)
+}
+
+func (l *Logger) log(level string, format string, args ...any) {
+	if logLevels[level] < l.level {
+		return
+	}
+	message := fmt.Sprintf(format, args...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case l.actions && level == LevelInfo:
+		fmt.Fprintln(l.out, message)
+	case l.actions:
+		fmt.Fprintf(l.out, "::%s::%s\n", level, escapeCommandData(message))
+	case l.json:
+		line, _ := json.Marshal(struct {
+			Time    string `json:"time"`
+			Level   string `json:"level"`
+			Message string `json:"msg"`
+		}{l.now().UTC().Format(time.RFC3339Nano), level, message})
+		fmt.Fprintf(l.out, "%s\n", line)
+	case level == LevelInfo:
+		fmt.Fprintf(l.out, "%s %s\n", l.now().Format("2006/01/02 15:04:05"), message)
+	default:
+		fmt.Fprintf(l.out, "%s %s: %s\n", l.now().Format("2006/01/02 15:04:05"), level, message)
+	}
+}
+
+// Group starts a collapsible group of lines in the Actions log and returns
+// the function ending it. Outside Actions it does nothing.
+func (l *Logger) Group(title string) func() {
+	if !l.actions {
+		return func() {}
+	}
+	l.mu.Lock()
+	fmt.Fprintf(l.out, "::group::%s\n", escapeCommandData(title))
+	l.mu.Unlock()
+	return func() {
+		l.mu.Lock()
+		fmt.Fprintln(l.out, "::endgroup::")
+		l.mu.Unlock()
+	}
+}
+
+// Mask registers secrets with Actions, which then hides them wherever they
+// appear in the log. Values shorter than minSecretLength are not masked, for
+// the same reason the redactor keeps them.
+func (l *Logger) Mask(secrets ...string) {
+	if !l.actions {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, secret := range secrets {
+		for _, line := range strings.Split(secret, "\n") {
+			if line = strings.TrimSpace(line); len(line) >= minSecretLength {
+				fmt.Fprintf(l.out, "::add-mask::%s\n", escapeCommandData(line))
+			}
+		}
+	}
+}
+
+// escapeCommandData escapes the message of a workflow command.
+func escapeCommandData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// maxMattermostTextLength is the longest post Mattermost accepts.
+const maxMattermostTextLength = 16383
+
+// mattermostProvider posts Slack compatible attachments to a Mattermost
+// incoming webhook.
+type mattermostProvider struct{}
+
+type mattermostMessage struct {
+	Attachments []mattermostAttachment `json:"attachments"`
+}
+
+type mattermostAttachment struct {
+	Fallback string            `json:"fallback"`
+	Color    string            `json:"color,omitempty"`
+	Title    string            `json:"title"`
+	Text     string            `json:"text,omitempty"`
+	Fields   []mattermostField `json:"fields,omitempty"`
+}
+
+type mattermostField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+func (mattermostProvider) Render(n Notification) (any, error) {
+	attachment := mattermostAttachment{
+		Fallback: n.Title,
+		Color:    n.Color,
+		Title:    n.Title,
+	}
+	text := MarkdownFromMrkdwn(n.Text)
+	if len(n.Buttons) > 0 {
+		links := make([]string, 0, len(n.Buttons))
+		for _, button := range n.Buttons {
+			links = append(links, fmt.Sprintf("[%s](%s)", button.Text, button.URL))
+		}
+		text = strings.TrimSpace(text + "\n\n" + strings.Join(links, " • "))
+	}
+	attachment.Text = truncate(text, maxMattermostTextLength)
+	for _, field := range n.Fields {
+		attachment.Fields = append(attachment.Fields, mattermostField{
+			Title: field.Title,
+			Value: MarkdownFromMrkdwn(field.Value),
+			Short: true,
+		})
+	}
+	return mattermostMessage{Attachments: []mattermostAttachment{attachment}}, nil
+}
+
+// Send posts the attachment; Mattermost answers 200 with a body of ok.
+func (mattermostProvider) Send(client *http.Client, webhookURL string, payload []byte) error {
+	_, err := postWebhook(client, webhookURL, payload)
+	return err
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMattermostGolden(t *testing.T) {
+	payload, err := mattermostProvider{}.Render(testNotification())
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	assertGolden(t, "mattermost/notification", payload)
+}
+
+func TestMattermostLimits(t *testing.T) {
+	rendered, err := mattermostProvider{}.Render(Notification{Title: "Deploy", Text: strings.Repeat("a", 20000)})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if got := len([]rune(rendered.(mattermostMessage).Attachments[0].Text)); got != maxMattermostTextLength {
+		t.Errorf("Expected the text to be cut to %d characters, got %d", maxMattermostTextLength, got)
+	}
+}
+
+func TestMattermostSend(t *testing.T) {
+	server, payloads := webhookServer(t, http.StatusOK, "ok")
+	if err := (mattermostProvider{}).Send(http.DefaultClient, server.URL, []byte(`{"attachments":[]}`)); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(*payloads) != 1 {
+		t.Errorf("Expected 1 webhook call, got %d", len(*payloads))
+	}
+}
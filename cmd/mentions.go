@@ -0,0 +1,197 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MentionOnFailure = "failure"
+	MentionOnAlways  = "always"
+	MentionOnNever   = "never"
+)
+
+// MentionRules decides who is mentioned and when. The same rules can be set
+// through inputs, a profile or a route.
+type MentionRules struct {
+	// Users are Slack user IDs or GitHub logins listed in the mapping file.
+	Users stringList `env:"INPUT_MENTION_USERS" yaml:"users"`
+	// Groups are Slack user group handles or IDs. Groups kept up to date by
+	// an on-call tool resolve to whoever is on call.
+	Groups stringList `env:"INPUT_MENTION_GROUPS" yaml:"groups"`
+	On     string     `env:"INPUT_MENTION_ON" yaml:"on"`
+	// CodeOwners mentions the owners of the changed files.
+	CodeOwners bool `env:"INPUT_MENTION_CODEOWNERS" yaml:"codeowners"`
+}
+
+// merge fills the rules left empty from other.
+func (m *MentionRules) merge(other MentionRules) {
+	if len(m.Users) == 0 {
+		m.Users = other.Users
+	}
+	if len(m.Groups) == 0 {
+		m.Groups = other.Groups
+	}
+	if m.On == "" {
+		m.On = other.On
+	}
+	m.CodeOwners = m.CodeOwners || other.CodeOwners
+}
+
+// validate checks the mention_on value.
+func (m MentionRules) validate() error {
+	switch m.On {
+	case "", MentionOnFailure, MentionOnAlways, MentionOnNever:
+		return nil
+	}
+	return fmt.Errorf("invalid mention_on %q, expected %s, %s or %s", m.On, MentionOnFailure, MentionOnAlways, MentionOnNever)
+}
+
+// applies reports whether anybody should be mentioned for status. Mentions
+// are sent on failure unless configured otherwise.
+func (m MentionRules) applies(status string) bool {
+	switch m.On {
+	case MentionOnAlways:
+		return true
+	case MentionOnNever:
+		return false
+	}
+	return status == "failure"
+}
+
+// MentionResolver turns mention rules into Slack mention syntax.
+type MentionResolver struct {
+	Users UserResolver
+	// UserGroups lists the workspace user groups; it is called at most once.
+	UserGroups func() ([]UserGroup, error)
+	// CodeOwners returns the owners of the changed files.
+	CodeOwners func() ([]string, error)
+
+	groups map[string]string
+}
+
+// MentionLine returns the line of mentions for status, or an empty string
+// when nobody is to be mentioned. Names that cannot be resolved are logged
+// and skipped so that a stale mapping never blocks a notification.
+func (r *MentionResolver) MentionLine(rules MentionRules, status string) (string, error) {
+	if !rules.applies(status) {
+		return "", nil
+	}
+	users := append([]string{}, rules.Users...)
+	groups := append([]string{}, rules.Groups...)
+
+	if rules.CodeOwners {
+		owners, err := r.CodeOwners()
+		if err != nil {
+			return "", err
+		}
+		for _, owner := range owners {
+			owner = strings.TrimPrefix(owner, "@")
+			if strings.Contains(owner, "/") {
+				if group, ok := r.Users.Mapping.Teams[owner]; ok {
+					groups = append(groups, group)
+					continue
+				}
+			} else if _, ok := r.Users.Mapping.Users[owner]; ok {
+				users = append(users, owner)
+				continue
+			}
+			logger.Warnf("code owner %s has no Slack mapping, not mentioned", owner)
+		}
+	}
+
+	var mentions []string
+	seen := map[string]bool{}
+	add := func(mention string) {
+		if !seen[mention] {
+			seen[mention] = true
+			mentions = append(mentions, mention)
+		}
+	}
+	for _, user := range users {
+		id, err := r.Users.Resolve(user)
+		if errors.Is(err, ErrUserNotFound) {
+			logger.Warnf("%v, not mentioned", err)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		add(fmt.Sprintf("<@%s>", id))
+	}
+	for _, group := range groups {
+		id, err := r.groupID(group)
+		if err != nil {
+			return "", err
+		}
+		if id == "" {
+			logger.Warnf("user group %s not found, not mentioned", group)
+			continue
+		}
+		add(fmt.Sprintf("<!subteam^%s>", id))
+	}
+	return strings.Join(mentions, " "), nil
+}
+
+func (r *MentionResolver) groupID(group string) (string, error) {
+	group = strings.TrimPrefix(group, "@")
+	if isSlackID(group, "S") {
+		return group, nil
+	}
+	if r.groups == nil {
+		list, err := r.UserGroups()
+		if err != nil {
+			return "", fmt.Errorf("error resolving user group %s: %v", group, err)
+		}
+		r.groups = map[string]string{}
+		for _, g := range list {
+			r.groups[g.Handle] = g.ID
+		}
+	}
+	return r.groups[group], nil
+}
+
+// withMentionLine prepends the mention line to the section text.
+func withMentionLine(text string, line string) string {
+	if line == "" {
+		return text
+	}
+	return line + "\n" + text
+}
+
+// resolveMentions resolves the mention line of e. In a dry run without a
+// token user groups cannot be looked up and are left out.
+func resolveMentions(e *Environment) error {
+	users, err := newUserResolver(e)
+	if err != nil {
+		return err
+	}
+	resolver := &MentionResolver{
+		Users:      users,
+		UserGroups: slackAPI.UserGroups,
+		CodeOwners: func() ([]string, error) {
+			files, err := e.GitHub.ChangedFiles()
+			if err != nil {
+				return nil, err
+			}
+			root := e.GitHub.Workspace
+			if root == "" {
+				root = "."
+			}
+			owners, err := LoadCodeOwners(root)
+			if err != nil {
+				return nil, err
+			}
+			return owners.OwnersOf(files), nil
+		},
+	}
+	if e.Slack.Token == "" {
+		resolver.UserGroups = func() ([]UserGroup, error) {
+			logger.Infof("dry run without a token, user groups are not resolved")
+			return nil, nil
+		}
+	}
+	e.Input.Mentions, err = resolver.MentionLine(e.Mention, e.Input.Status)
+	return err
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func testResolver(owners ...string) *MentionResolver {
+	return &MentionResolver{
+		Users: UserResolver{
+			Mapping: &UserMapping{
+				Users: map[string]string{"octocat": "U0OCTOCAT"},
+				Teams: map[string]string{"acme/platform": "platform-oncall"},
+			},
+			LookupByEmail: func(email string) (string, error) {
+				if email == "mona@example.com" {
+					return "U0MONA", nil
+				}
+				return "", &SlackError{Method: "users.lookupByEmail", Code: "users_not_found"}
+			},
+		},
+		UserGroups: func() ([]UserGroup, error) {
+			return []UserGroup{
+				{ID: "S0PLATFORM", Handle: "platform-oncall"},
+				{ID: "S0RELEASE", Handle: "release"},
+			}, nil
+		},
+		CodeOwners: func() ([]string, error) { return owners, nil },
+	}
+}
+
+func TestMentionLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  MentionRules
+		status string
+		want   string
+	}{
+		{
+			name:   "Failure mentions users and groups",
+			rules:  MentionRules{Users: stringList{"U012AB3CD", "@octocat", "mona@example.com"}, Groups: stringList{"@release", "S0OTHER"}},
+			status: "failure",
+			want:   "<@U012AB3CD> <@U0OCTOCAT> <@U0MONA> <!subteam^S0RELEASE> <!subteam^S0OTHER>",
+		},
+		{
+			name:   "Success does not mention by default",
+			rules:  MentionRules{Users: stringList{"U012AB3CD"}},
+			status: "success",
+			want:   "",
+		},
+		{
+			name:   "Always mentions on success",
+			rules:  MentionRules{Users: stringList{"U012AB3CD"}, On: MentionOnAlways},
+			status: "success",
+			want:   "<@U012AB3CD>",
+		},
+		{
+			name:   "Never mentions on failure",
+			rules:  MentionRules{Users: stringList{"U012AB3CD"}, On: MentionOnNever},
+			status: "failure",
+			want:   "",
+		},
+		{
+			name:   "Unknown users and groups are skipped",
+			rules:  MentionRules{Users: stringList{"someone", "nobody@example.com"}, Groups: stringList{"missing"}},
+			status: "failure",
+			want:   "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := testResolver().MentionLine(tt.rules, tt.status)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMentionLineCodeOwners(t *testing.T) {
+	resolver := testResolver("@octocat", "@acme/platform", "@unmapped", "@octocat")
+	got, err := resolver.MentionLine(MentionRules{CodeOwners: true}, "failure")
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if want := "<@U0OCTOCAT> <!subteam^S0PLATFORM>"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestMentionLineUserGroupsError(t *testing.T) {
+	resolver := testResolver()
+	resolver.UserGroups = func() ([]UserGroup, error) { return nil, errors.New("missing_scope") }
+	if _, err := resolver.MentionLine(MentionRules{Groups: stringList{"release"}}, "failure"); err == nil {
+		t.Error("Expected error when user groups cannot be listed")
+	}
+}
+
+func TestMentionRulesValidate(t *testing.T) {
+	if err := (MentionRules{On: "sometimes"}).validate(); err == nil {
+		t.Error("Expected error for an invalid mention_on value")
+	}
+	if err := (MentionRules{On: MentionOnAlways}).validate(); err != nil {
+		t.Errorf("Expected no error but got: %v", err)
+	}
+}
+
+func TestBuildMessageWithMentions(t *testing.T) {
+	envVar = Environment{}
+	envVar.Input.Title = "Build failed"
+	envVar.Input.Text = "See logs"
+	envVar.Input.Mentions = "<@U012AB3CD>"
+
+	message := buildMessage("alerts")
+	if got := message.Blocks[1].Text.Text; got != "<@U012AB3CD>\nSee logs" {
+		t.Errorf("Expected mention line before the text, got %q", got)
+	}
+}
+
+func TestProfileMentionRules(t *testing.T) {
+	config := testConfig + `    mention:
+      groups: [platform-oncall]
+      on: always
+`
+	setProfileEnv(t, writeTestConfig(t, config), "deploy")
+	t.Setenv("INPUT_MENTION_USERS", "U012AB3CD, U045EF6GH")
+
+	if err := initializeApp(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(envVar.Mention.Users) != 2 || envVar.Mention.Users[1] != "U045EF6GH" {
+		t.Errorf("Expected users from the input, got %v", envVar.Mention.Users)
+	}
+	if len(envVar.Mention.Groups) != 1 || envVar.Mention.On != MentionOnAlways {
+		t.Errorf("Expected groups and mention_on from the profile, got %+v", envVar.Mention)
+	}
+}
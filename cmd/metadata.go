@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// defaultMetadataEventType is the event type of the metadata attached from
+// the GitHub context when INPUT_METADATA_EVENT_TYPE is not set.
+const defaultMetadataEventType = "github_workflow_run"
+
+// messageKeyEventType is the event type of the metadata naming a keyed
+// message that has no other metadata attached.
+const messageKeyEventType = "message_slack_message"
+
+// messageKeyField is the payload field that holds the key of a keyed message.
+const messageKeyField = "message_key"
+
+// MessageMetadata is Slack message metadata. It is not shown in Slack but
+// returned with the message, so that other apps can read the notification
+// without parsing its text.
+type MessageMetadata struct {
+	EventType    string         `json:"event_type"`
+	EventPayload map[string]any `json:"event_payload"`
+}
+
+// BuildMetadata returns the metadata for e. The payload holds the repository,
+// sha, run ID and status of the run, overridden and extended by the JSON
+// object in payload. It returns nil when there is nothing to attach, such as
+// when running outside of GitHub Actions without metadata inputs.
+func BuildMetadata(e *Environment, eventType string, payload string) (*MessageMetadata, error) {
+	fields := map[string]any{}
+	for key, value := range map[string]string{
+		"repository": e.GitHub.Repository,
+		"sha":        e.GitHub.SHA,
+		"run_id":     e.GitHub.RunID,
+		"status":     e.Input.Status,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if payload != "" {
+		var custom map[string]any
+		if err := json.Unmarshal([]byte(payload), &custom); err != nil {
+			return nil, fmt.Errorf("invalid metadata_payload, expected a JSON object: %v", err)
+		}
+		for key, value := range custom {
+			fields[key] = value
+		}
+	}
+
+	if len(fields) == 0 && eventType == "" {
+		return nil, nil
+	}
+	if eventType == "" {
+		eventType = defaultMetadataEventType
+	}
+	return &MessageMetadata{EventType: eventType, EventPayload: fields}, nil
+}
+
+// withMessageKey returns metadata with key added to its payload, so that the
+// channel state backend can find the message. A message carries a single
+// metadata, so the key joins the metadata of the inputs when there is one.
+func withMessageKey(metadata *MessageMetadata, key string) *MessageMetadata {
+	keyed := &MessageMetadata{EventType: messageKeyEventType, EventPayload: map[string]any{}}
+	if metadata != nil {
+		keyed.EventType = metadata.EventType
+		for name, value := range metadata.EventPayload {
+			keyed.EventPayload[name] = value
+		}
+	}
+	keyed.EventPayload[messageKeyField] = key
+	return keyed
+}
+
+// outgoingMessage is a message as sent to chat.postMessage, chat.update and
+// chat.scheduleMessage. slack.Message has no metadata field.
+type outgoingMessage struct {
+	slack.Message
+	Metadata *MessageMetadata `json:"metadata,omitempty"`
+}
+
+// PostMessage posts message to channel with metadata attached.
+func (w *webAPI) PostMessage(channel string, message slack.Message, metadata *MessageMetadata) (slack.MessageRef, error) {
+	message.Channel = channel
+	var ref slack.MessageRef
+	err := w.callJSON("chat.postMessage", outgoingMessage{message, metadata}, &ref)
+	return ref, err
+}
+
+// metadataSender posts and updates messages with metadata attached.
+type metadataSender interface {
+	PostMessage(channel string, message slack.Message, metadata *MessageMetadata) (slack.MessageRef, error)
+	updateMessage(item slack.MessageRef, message slack.Message, metadata *MessageMetadata) error
+}
+
+// metadataClient posts and updates messages with the same metadata attached.
+type metadataClient struct {
+	api      metadataSender
+	metadata *MessageMetadata
+}
+
+func (c metadataClient) AddFormattedMessage(channel string, message slack.Message) (slack.MessageRef, error) {
+	return c.api.PostMessage(channel, message, c.metadata)
+}
+
+func (c metadataClient) UpdateMessage(item slack.MessageRef, message slack.Message) error {
+	return c.api.updateMessage(item, message, c.metadata)
+}
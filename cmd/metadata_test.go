@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildMetadata(t *testing.T) {
+	tests := []struct {
+		name            string
+		github          GitHubContext
+		status          string
+		eventType       string
+		payload         string
+		expectNil       bool
+		expectErr       bool
+		expectEventType string
+		expectPayload   map[string]any
+	}{
+		{
+			name:            "GitHub context",
+			github:          GitHubContext{Repository: "pal-paul/message-slack", SHA: "abc123", RunID: "42"},
+			status:          "success",
+			expectEventType: defaultMetadataEventType,
+			expectPayload:   map[string]any{"repository": "pal-paul/message-slack", "sha": "abc123", "run_id": "42", "status": "success"},
+		},
+		{
+			name:            "Custom payload overrides and extends",
+			github:          GitHubContext{Repository: "pal-paul/message-slack", SHA: "abc123"},
+			eventType:       "deploy_finished",
+			payload:         `{"sha":"def456","environment":"production","canary":true}`,
+			expectEventType: "deploy_finished",
+			expectPayload:   map[string]any{"repository": "pal-paul/message-slack", "sha": "def456", "environment": "production", "canary": true},
+		},
+		{
+			name:      "Nothing to attach",
+			expectNil: true,
+		},
+		{
+			name:      "Payload not an object",
+			payload:   `["production"]`,
+			expectErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Environment{GitHub: tt.github}
+			e.Input.Status = tt.status
+
+			metadata, err := BuildMetadata(e, tt.eventType, tt.payload)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if tt.expectNil {
+				if metadata != nil {
+					t.Errorf("Expected no metadata, got %+v", metadata)
+				}
+				return
+			}
+			if metadata.EventType != tt.expectEventType {
+				t.Errorf("Expected event type %s, got %s", tt.expectEventType, metadata.EventType)
+			}
+			if len(metadata.EventPayload) != len(tt.expectPayload) {
+				t.Errorf("Expected payload %v, got %v", tt.expectPayload, metadata.EventPayload)
+			}
+			for key, value := range tt.expectPayload {
+				if metadata.EventPayload[key] != value {
+					t.Errorf("Expected %s=%v, got %v", key, value, metadata.EventPayload[key])
+				}
+			}
+		})
+	}
+}
+
+func TestPostWithMetadata(t *testing.T) {
+	var body struct {
+		Channel  string          `json:"channel"`
+		Blocks   []any           `json:"blocks"`
+		Metadata MessageMetadata `json:"metadata"`
+	}
+	slackAPI = newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chat.getPermalink" {
+			w.Write([]byte(`{"ok":true,"permalink":"https://example.slack.com/archives/C0DEPLOYS/p1700000000000100"}`))
+			return
+		}
+		if r.URL.Path != "/chat.postMessage" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"ok":true,"channel":"C0DEPLOYS","ts":"1700000000.000100"}`))
+	})
+	t.Setenv("GITHUB_OUTPUT", filepath.Join(t.TempDir(), "outputs"))
+
+	envVar = Environment{}
+	envVar.Input.Title = "Deploy"
+	envVar.Input.Text = "Deployed to production"
+	envVar.Slack.Channel = "deployments"
+	envVar.Input.Metadata = &MessageMetadata{
+		EventType:    "deploy_finished",
+		EventPayload: map[string]any{"sha": "abc123"},
+	}
+
+	if err := run(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if body.Channel != "deployments" || len(body.Blocks) != 2 {
+		t.Errorf("Unexpected request body %+v", body)
+	}
+	if body.Metadata.EventType != "deploy_finished" || body.Metadata.EventPayload["sha"] != "abc123" {
+		t.Errorf("Expected the metadata to be sent, got %+v", body.Metadata)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// defaultStatusColors are the colors of the statuses for providers that
+// color messages.
+var defaultStatusColors = map[string]string{
+	"success":   "#2EB67D",
+	"failure":   "#E01E5A",
+	"cancelled": "#868686",
+}
+
+// Notification is the backend-neutral form of a message. Providers other
+// than Slack render it into their own payloads. Text is kept in Slack mrkdwn
+// and converted by each provider.
+type Notification struct {
+	Title   string
+	Text    string
+	Fields  Fields
+	Buttons Buttons
+	Status  string
+	// Color is a hex color such as #2EB67D, or empty.
+	Color string
+}
+
+// slackLink matches a mrkdwn link, <url|text> or <url>.
+var slackLink = regexp.MustCompile(`<((?:https?|mailto):[^|>]+)(?:\|([^>]+))?>`)
+
+// NotificationFromSlack translates a message built by SlackMessageBuilder,
+// FieldsBlocks and ButtonsBlocks back into a Notification. Header blocks give
+// the title, field sections the fields and a section made of links only the
+// buttons; the text of any other section becomes a paragraph of the text.
+func NotificationFromSlack(message slack.Message, status string) Notification {
+	n := Notification{Status: status, Color: defaultStatusColors[status]}
+	var paragraphs []string
+	for _, block := range message.Blocks {
+		switch {
+		case block.Type == slack.HeaderBlock && block.Text != nil:
+			n.Title = block.Text.Text
+		case len(block.Fields) > 0:
+			for _, field := range block.Fields {
+				title, value, _ := strings.Cut(field.Text, "\n")
+				n.Fields = append(n.Fields, Field{Title: strings.Trim(title, "*"), Value: value})
+			}
+		case block.Text != nil:
+			if buttons, ok := parseButtons(block.Text.Text); ok {
+				n.Buttons = append(n.Buttons, buttons...)
+				continue
+			}
+			paragraphs = append(paragraphs, block.Text.Text)
+		}
+	}
+	n.Text = strings.Join(paragraphs, "\n\n")
+	return n
+}
+
+// parseButtons parses the text of a ButtonsBlocks section.
+func parseButtons(text string) (Buttons, bool) {
+	var buttons Buttons
+	for _, part := range strings.Split(text, buttonSeparator) {
+		m := slackLink.FindStringSubmatch(part)
+		if m == nil || m[0] != part || m[2] == "" {
+			return nil, false
+		}
+		buttons = append(buttons, Button{Text: m[2], URL: m[1]})
+	}
+	return buttons, true
+}
+
+var (
+	mrkdwnBold   = regexp.MustCompile(`(^|[\s(])\*([^*\n]+)\*`)
+	mrkdwnItalic = regexp.MustCompile(`(^|[\s(])_([^_\n]+)_`)
+	mrkdwnStrike = regexp.MustCompile(`(^|[\s(])~([^~\n]+)~`)
+	mrkdwnCode   = regexp.MustCompile("`([^`\n]+)`")
+	slackMention = regexp.MustCompile(`<[@#]([A-Z0-9]+)(?:\|([^>]+))?>`)
+	slackSpecial = regexp.MustCompile(`<!([^>|]+)(?:\|([^>]+))?>`)
+	htmlEntities = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+	htmlEscaper  = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+)
+
+// mentionText replaces Slack user, channel and group mentions, which other
+// services cannot resolve, with their label or ID.
+func mentionText(text string) string {
+	text = slackMention.ReplaceAllStringFunc(text, func(m string) string {
+		sub := slackMention.FindStringSubmatch(m)
+		if sub[2] != "" {
+			return "@" + sub[2]
+		}
+		return "@" + sub[1]
+	})
+	return slackSpecial.ReplaceAllStringFunc(text, func(m string) string {
+		sub := slackSpecial.FindStringSubmatch(m)
+		if sub[2] != "" {
+			return "@" + strings.TrimPrefix(sub[2], "@")
+		}
+		return "@" + strings.TrimPrefix(sub[1], "subteam^")
+	})
+}
+
+// MarkdownFromMrkdwn converts Slack mrkdwn to the CommonMark dialect used by
+// Teams, Discord and Mattermost.
+func MarkdownFromMrkdwn(text string) string {
+	text = mentionText(text)
+	text = slackLink.ReplaceAllStringFunc(text, func(m string) string {
+		sub := slackLink.FindStringSubmatch(m)
+		if sub[2] == "" {
+			return sub[1]
+		}
+		return fmt.Sprintf("[%s](%s)", sub[2], sub[1])
+	})
+	text = mrkdwnBold.ReplaceAllString(text, "$1**$2**")
+	text = mrkdwnStrike.ReplaceAllString(text, "$1~~$2~~")
+	return htmlEntities.Replace(text)
+}
+
+// HTMLFromMrkdwn converts Slack mrkdwn to the HTML subset understood by
+// Google Chat cards.
+func HTMLFromMrkdwn(text string) string {
+	text = mentionText(text)
+	var b strings.Builder
+	last := 0
+	for _, m := range slackLink.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(htmlFormat(text[last:m[0]]))
+		url := text[m[2]:m[3]]
+		label := url
+		if m[4] >= 0 {
+			label = text[m[4]:m[5]]
+		}
+		fmt.Fprintf(&b, `<a href="%s">%s</a>`, htmlEscaper.Replace(htmlEntities.Replace(url)), htmlFormat(label))
+		last = m[1]
+	}
+	b.WriteString(htmlFormat(text[last:]))
+	return b.String()
+}
+
+// htmlFormat escapes text and turns mrkdwn formatting into HTML tags.
+func htmlFormat(text string) string {
+	text = htmlEscaper.Replace(htmlEntities.Replace(text))
+	text = mrkdwnBold.ReplaceAllString(text, "$1<b>$2</b>")
+	text = mrkdwnItalic.ReplaceAllString(text, "$1<i>$2</i>")
+	text = mrkdwnStrike.ReplaceAllString(text, "$1<s>$2</s>")
+	text = mrkdwnCode.ReplaceAllString(text, `<font face="monospace">$1</font>`)
+	return strings.ReplaceAll(text, "\n", "<br>")
+}
+
+// truncate shortens s to at most max characters, marking the cut with an
+// ellipsis.
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
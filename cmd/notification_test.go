@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// testNotification is the notification rendered by the provider golden
+// tests.
+func testNotification() Notification {
+	envVar = Environment{}
+	envVar.Input.Title = "Deploy finished"
+	envVar.Input.Text = "Version *1.4.0* is live in `production` <@U0MONA|mona>\nSee <https://example.com/changelog?a=1&b=2|the changelog>"
+	envVar.Input.Fields = Fields{{Title: "Environment", Value: "production"}, {Title: "Duration", Value: "_4m 12s_"}}
+	envVar.Input.Buttons = Buttons{{Text: "View run", URL: "https://github.com/pal-paul/message-slack/actions/runs/42"}}
+	return NotificationFromSlack(buildMessage(""), "success")
+}
+
+func TestNotificationFromSlack(t *testing.T) {
+	n := testNotification()
+
+	if n.Title != "Deploy finished" {
+		t.Errorf("Expected title from the header, got %q", n.Title)
+	}
+	if !strings.HasPrefix(n.Text, "Version *1.4.0*") {
+		t.Errorf("Expected text from the section, got %q", n.Text)
+	}
+	if len(n.Fields) != 2 || n.Fields[1] != (Field{Title: "Duration", Value: "_4m 12s_"}) {
+		t.Errorf("Unexpected fields %+v", n.Fields)
+	}
+	if len(n.Buttons) != 1 || n.Buttons[0].URL != "https://github.com/pal-paul/message-slack/actions/runs/42" {
+		t.Errorf("Unexpected buttons %+v", n.Buttons)
+	}
+	if n.Color != defaultStatusColors["success"] {
+		t.Errorf("Expected the default success color, got %q", n.Color)
+	}
+}
+
+func TestNotificationFromSlackKeepsLinkText(t *testing.T) {
+	message := SlackMessageBuilder("Deploy", "See <https://example.com|the logs> for details", "")
+	n := NotificationFromSlack(message, "")
+	if len(n.Buttons) != 0 || n.Text != "See <https://example.com|the logs> for details" {
+		t.Errorf("Expected a text with a link to stay text, got %+v", n)
+	}
+}
+
+func TestMarkdownFromMrkdwn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"*bold* and _italic_", "**bold** and _italic_"},
+		{"~gone~", "~~gone~~"},
+		{"<https://example.com|site> <https://example.com>", "[site](https://example.com) https://example.com"},
+		{"<@U0MONA> <@U0OCTO|octocat> <!subteam^S0ONCALL> <!here>", "@U0MONA @octocat @S0ONCALL @here"},
+		{"a &lt; b &amp;&amp; c", "a < b && c"},
+		{"2*3*4", "2*3*4"},
+	}
+	for _, tt := range tests {
+		if got := MarkdownFromMrkdwn(tt.input); got != tt.expected {
+			t.Errorf("MarkdownFromMrkdwn(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestHTMLFromMrkdwn(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"*bold* _italic_ ~gone~ `code`", `<b>bold</b> <i>italic</i> <s>gone</s> <font face="monospace">code</font>`},
+		{"<https://example.com/?a=1&b=2|a *site*>", `<a href="https://example.com/?a=1&amp;b=2">a <b>site</b></a>`},
+		{"1 < 2\nnext", "1 &lt; 2<br>next"},
+	}
+	for _, tt := range tests {
+		if got := HTMLFromMrkdwn(tt.input); got != tt.expected {
+			t.Errorf("HTMLFromMrkdwn(%q) = %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("déploiement", 5); got != "dépl…" {
+		t.Errorf("Expected dépl…, got %q", got)
+	}
+	if got := truncate("short", 5); got != "short" {
+		t.Errorf("Expected short, got %q", got)
+	}
+}
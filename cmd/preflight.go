@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// commandValidate is the subcommand that checks the inputs, the token and
+// its scopes without sending anything.
+const commandValidate = "validate"
+
+// AuthInfo is what auth.test tells about a token.
+type AuthInfo struct {
+	URL    string `json:"url"`
+	Team   string `json:"team"`
+	User   string `json:"user"`
+	TeamID string `json:"team_id"`
+	UserID string `json:"user_id"`
+	BotID  string `json:"bot_id"`
+	// Scopes are the OAuth scopes granted to the token. They are nil when
+	// Slack does not report them.
+	Scopes []string `json:"-"`
+}
+
+// hasScope reports whether the token was granted scope.
+func (a AuthInfo) hasScope(scope string) bool {
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthTest checks the token and returns whom it belongs to. Slack reports the
+// granted scopes in the X-OAuth-Scopes header.
+func (w *webAPI) AuthTest() (AuthInfo, error) {
+	var info AuthInfo
+	header, err := w.doWithHeader("auth.test", "application/x-www-form-urlencoded", []byte(url.Values{}.Encode()), &info)
+	if err != nil {
+		return info, err
+	}
+	if scopes := header.Get("X-OAuth-Scopes"); scopes != "" {
+		for _, scope := range strings.Split(scopes, ",") {
+			info.Scopes = append(info.Scopes, strings.TrimSpace(scope))
+		}
+	}
+	return info, nil
+}
+
+// scopeRequirement is a feature and the scopes it needs; any one of them
+// will do.
+type scopeRequirement struct {
+	feature string
+	scopes  []string
+}
+
+var historyScopes = []string{"channels:history", "groups:history"}
+
+// requiredScopes returns the scopes needed by the inputs.
+func (e *Environment) requiredScopes() []scopeRequirement {
+	var required []scopeRequirement
+	need := func(feature string, scopes ...string) {
+		required = append(required, scopeRequirement{feature, scopes})
+	}
+
+	if e.resolvesChannels() {
+		need("looking up channels", "channels:read", "groups:read")
+	}
+	if e.Slack.AutoJoin {
+		need("joining channels", "channels:join")
+	}
+	switch e.Operation {
+	case OperationReact:
+		need("reactions", "reactions:write")
+	case OperationCancel:
+		need("cancelling scheduled messages", "chat:write")
+	case OperationDelete:
+		need("deleting messages", "chat:write")
+		if e.Slack.MessageKey != "" && e.Slack.StateBackend == StateBackendChannel {
+			need("the channel state backend", historyScopes...)
+		}
+	default:
+		if !e.reactionOnly() {
+			need("posting messages", "chat:write")
+		}
+		if e.Slack.MessageTS != "" && e.Input.Status != "" {
+			need("status reactions", "reactions:write")
+		}
+		if e.Digest.Key != "" {
+			need("digests", historyScopes...)
+		}
+		if e.Slack.StateBackend == StateBackendChannel && (e.Slack.MessageKey != "" || e.Dedupe.Window != "") {
+			need("the channel state backend", historyScopes...)
+		}
+		if strings.Contains(e.Slack.EphemeralUser, "@") && !strings.HasPrefix(e.Slack.EphemeralUser, "@") {
+			need("ephemeral messages to an email address", "users:read.email")
+		}
+		if e.Mention.applies(e.Input.Status) {
+			for _, user := range e.Mention.Users {
+				if strings.Contains(strings.TrimPrefix(user, "@"), "@") {
+					need("mentions by email address", "users:read.email")
+					break
+				}
+			}
+			if len(e.Mention.Groups) > 0 {
+				need("user group mentions", "usergroups:read")
+			}
+		}
+	}
+	return required
+}
+
+// missingScopes returns an error naming every scope the token lacks for the
+// requested features.
+func missingScopes(info AuthInfo, required []scopeRequirement) error {
+	var missing []string
+	for _, r := range required {
+		granted := false
+		for _, scope := range r.scopes {
+			granted = granted || info.hasScope(scope)
+		}
+		if !granted {
+			missing = append(missing, fmt.Sprintf("missing scope %s for %s", strings.Join(r.scopes, " or "), r.feature))
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return fmt.Errorf("the slack token lacks scopes: %s", strings.Join(missing, "; "))
+}
+
+// preflight checks the token with auth.test before anything is sent, and
+// that it was granted the scopes the inputs need, so that a misconfigured
+// token fails before half of the work is done.
+func preflight() error {
+	if envVar.Skip || envVar.webhookProvider() != nil {
+		return nil
+	}
+	if !ValidateSlackToken(envVar.Slack.Token) {
+		return fmt.Errorf("invalid slack token: expected a token starting with %s; app-level (xapp-) and refresh (xoxe-) tokens cannot post messages", strings.Join(slackTokenPrefixes, ", "))
+	}
+	var info AuthInfo
+	err := newRetryPolicy(envVar.Slack.Retries).do("auth.test", func() error {
+		var err error
+		info, err = slackAPI.AuthTest()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("error while checking the slack token: %w", err)
+	}
+	logger.Infof("authenticated as %s in %s", info.User, info.Team)
+	if info.Scopes == nil {
+		logger.Warnf("slack did not report the scopes of the token, not checking them")
+		return nil
+	}
+	return missingScopes(info, envVar.requiredScopes())
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	env "github.com/pal-paul/go-libraries/pkg/env"
+)
+
+const (
+	ProviderSlack      = "slack"
+	ProviderTeams      = "teams"
+	ProviderDiscord    = "discord"
+	ProviderMattermost = "mattermost"
+	ProviderGoogleChat = "googlechat"
+)
+
+// Provider renders notifications for a chat service reached through an
+// incoming webhook, and sends them there.
+type Provider interface {
+	// Render returns the webhook payload for n, shortened to the limits of
+	// the service.
+	Render(n Notification) (any, error)
+	// Send posts a rendered payload to the webhook.
+	Send(client *http.Client, webhookURL string, payload []byte) error
+}
+
+// providers are the webhook providers by name. Slack is not one of them; it
+// is served by the Web API clients.
+var providers = map[string]Provider{
+	ProviderTeams:      teamsProvider{},
+	ProviderDiscord:    discordProvider{},
+	ProviderMattermost: mattermostProvider{},
+	ProviderGoogleChat: googleChatProvider{},
+}
+
+// webhookProvider returns the provider selected by INPUT_PROVIDER, or nil
+// for Slack.
+func (e *Environment) webhookProvider() Provider {
+	return providers[e.Provider.Name]
+}
+
+// validateProvider checks the inputs of a webhook provider. Only posting is
+// supported and the Slack specific inputs are rejected rather than ignored.
+func (e *Environment) validateProvider() error {
+	if e.webhookProvider() == nil {
+		names := []string{ProviderSlack}
+		for name := range providers {
+			names = append(names, name)
+		}
+		sort.Strings(names[1:])
+		return fmt.Errorf("invalid provider %q, expected one of %s", e.Provider.Name, strings.Join(names, ", "))
+	}
+	required := []requiredInput{
+		{"INPUT_TITLE", e.Input.Title},
+		{"INPUT_TEXT", e.Input.Text},
+	}
+	if !e.Input.DryRun {
+		required = append(required, requiredInput{"INPUT_WEBHOOK_URL", e.Provider.WebhookURL})
+	}
+	for _, r := range required {
+		if r.value == "" {
+			return &env.ErrMissingRequiredValue{Value: r.name}
+		}
+	}
+	if e.Operation != "" && e.Operation != OperationPost {
+		return fmt.Errorf("operation %s is only supported by the slack provider", e.Operation)
+	}
+	unsupported := []requiredInput{
+		{"INPUT_POST_AT", e.Input.PostAt},
+		{"INPUT_MESSAGE_KEY", e.Slack.MessageKey},
+		{"INPUT_MESSAGE_TS", e.Slack.MessageTS},
+		{"INPUT_EPHEMERAL_USER", e.Slack.EphemeralUser},
+		{"INPUT_DIGEST_KEY", e.Digest.Key},
+	}
+	for _, input := range unsupported {
+		if input.value != "" {
+			return fmt.Errorf("%s is only supported by the slack provider", input.name)
+		}
+	}
+	return nil
+}
+
+// sendToProvider renders the message for the webhook provider and sends it.
+func sendToProvider(provider Provider) error {
+	before := redactor.Count()
+	message := buildMessage("")
+	if err := reportRedactions(redactor.Count() - before); err != nil {
+		return err
+	}
+	notification := NotificationFromSlack(message, envVar.Input.Status)
+	rendered, err := provider.Render(notification)
+	if err != nil {
+		return fmt.Errorf("error while rendering %s message: %v", envVar.Provider.Name, err)
+	}
+	payload, err := json.Marshal(rendered)
+	if err != nil {
+		return err
+	}
+
+	if envVar.Input.DryRun {
+		indented, err := json.MarshalIndent(rendered, "", "  ")
+		if err != nil {
+			return err
+		}
+		endGroup := logger.Group(envVar.Provider.Name + " message payload")
+		fmt.Fprintln(os.Stdout, string(indented))
+		endGroup()
+		logger.Noticef("dry run: %s message not sent", envVar.Provider.Name)
+		return nil
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	if err := provider.Send(client, envVar.Provider.WebhookURL, payload); err != nil {
+		return fmt.Errorf("error while sending message to %s: %v", envVar.Provider.Name, err)
+	}
+	return nil
+}
+
+// postWebhook posts payload as JSON to webhookURL and returns the response
+// body. Any 2xx status counts as success.
+func postWebhook(client *http.Client, webhookURL string, payload []byte) ([]byte, error) {
+	resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		// The URL holds the webhook secret, keep it out of logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("error calling webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("webhook returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	// Time zones are embedded so that windows work on runners without a
+	// zoneinfo database.
+	_ "time/tzdata"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	PriorityLow      = "low"
+	PriorityNormal   = "normal"
+	PriorityHigh     = "high"
+	PriorityCritical = "critical"
+)
+
+// priorities orders the message priorities.
+var priorities = map[string]int{
+	PriorityLow:      1,
+	PriorityNormal:   2,
+	PriorityHigh:     3,
+	PriorityCritical: 4,
+}
+
+// What happens to a message held back by quiet hours.
+const (
+	QuietSchedule = "schedule"
+	QuietDrop     = "drop"
+	QuietSilent   = "silent"
+)
+
+// QuietHours is a window in which messages are held back. Start and End are
+// "15:04" times in Timezone; a window ending before it starts runs past
+// midnight. Days, if set, restrict the days the window starts on.
+type QuietHours struct {
+	// Channels the window applies to; all channels when empty.
+	Channels stringList `yaml:"channels"`
+	Timezone string     `yaml:"timezone"`
+	Start    string     `yaml:"start"`
+	End      string     `yaml:"end"`
+	Days     stringList `yaml:"days"`
+	// Action is schedule, the default, drop or silent.
+	Action string `yaml:"action"`
+	// Bypass is the lowest priority still sent right away, normal by
+	// default, so that only low priority messages are held back. Critical
+	// messages are always sent.
+	Bypass string `yaml:"bypass"`
+
+	location   *time.Location
+	start, end time.Duration
+	days       map[time.Weekday]bool
+}
+
+// QuietHoursList is a list of windows. As an input it is written as a YAML
+// list using the same schema as the quiet_hours section of the
+// configuration file.
+type QuietHoursList []QuietHours
+
+// UnmarshalEnvironmentValue implements env.Unmarshaler.
+func (q *QuietHoursList) UnmarshalEnvironmentValue(data string) error {
+	if strings.TrimSpace(data) == "" {
+		return nil
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(q); err != nil {
+		return fmt.Errorf("invalid quiet_hours: %v", err)
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// validate checks the window and prepares it for use.
+func (q *QuietHours) validate() error {
+	if q.Timezone == "" {
+		return fmt.Errorf("invalid quiet_hours: timezone is required")
+	}
+	var err error
+	if q.location, err = time.LoadLocation(q.Timezone); err != nil {
+		return fmt.Errorf("invalid quiet_hours: unknown timezone %q", q.Timezone)
+	}
+	if q.start, err = parseClock(q.Start); err != nil {
+		return fmt.Errorf("invalid quiet_hours start: %v", err)
+	}
+	if q.end, err = parseClock(q.End); err != nil {
+		return fmt.Errorf("invalid quiet_hours end: %v", err)
+	}
+	if q.start == q.end {
+		return fmt.Errorf("invalid quiet_hours: start and end are both %s", q.Start)
+	}
+	q.days = nil
+	for _, day := range q.Days {
+		name := strings.ToLower(day)
+		if len(name) > 3 {
+			name = name[:3]
+		}
+		weekday, ok := weekdays[name]
+		if !ok {
+			return fmt.Errorf("invalid quiet_hours day %q", day)
+		}
+		if q.days == nil {
+			q.days = map[time.Weekday]bool{}
+		}
+		q.days[weekday] = true
+	}
+	switch q.Action {
+	case "":
+		q.Action = QuietSchedule
+	case QuietSchedule, QuietDrop, QuietSilent:
+	default:
+		return fmt.Errorf("invalid quiet_hours action %q, expected %s, %s or %s", q.Action, QuietSchedule, QuietDrop, QuietSilent)
+	}
+	if q.Bypass == "" {
+		q.Bypass = PriorityNormal
+	}
+	if _, ok := priorities[q.Bypass]; !ok {
+		return fmt.Errorf("invalid quiet_hours bypass %q, expected %s", q.Bypass, priorityNames())
+	}
+	return nil
+}
+
+// parseClock parses a "15:04" time of day.
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a time such as 22:00, got %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// appliesTo reports whether the window covers channel, given by name, #name
+// or ID.
+func (q *QuietHours) appliesTo(channel string) bool {
+	if len(q.Channels) == 0 {
+		return true
+	}
+	for _, c := range q.Channels {
+		if strings.EqualFold(strings.TrimPrefix(c, "#"), strings.TrimPrefix(channel, "#")) {
+			return true
+		}
+	}
+	return false
+}
+
+// Until returns the end of the window if now is inside it.
+func (q *QuietHours) Until(now time.Time) (time.Time, bool) {
+	local := now.In(q.location)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, q.location)
+	// A window that runs past midnight may have started the day before.
+	for _, offset := range []int{-1, 0} {
+		day := midnight.AddDate(0, 0, offset)
+		if q.days != nil && !q.days[day.Weekday()] {
+			continue
+		}
+		start := clockOn(day, q.start)
+		end := clockOn(day, q.end)
+		if !end.After(start) {
+			end = clockOn(day.AddDate(0, 0, 1), q.end)
+		}
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// clockOn returns the time of day on the date of day, in its location.
+func clockOn(day time.Time, clock time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, day.Location())
+}
+
+// priority is the priority of the message, normal unless INPUT_PRIORITY
+// says otherwise.
+func (e *Environment) priority() string {
+	if e.Input.Priority == "" {
+		return PriorityNormal
+	}
+	return e.Input.Priority
+}
+
+func priorityNames() string {
+	return strings.Join([]string{PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical}, ", ")
+}
+
+// quietHours returns the window holding back a message of priority to
+// channel at now, and the end of that window. It reports false when the
+// message can be sent right away.
+func (e *Environment) quietHours(channel string, now time.Time) (*QuietHours, time.Time, bool) {
+	priority := priorities[e.priority()]
+	for i := range e.Config.QuietHours {
+		q := &e.Config.QuietHours[i]
+		applies := q.appliesTo(channel) || q.appliesTo(e.ChannelNames[channel])
+		if !applies || priority >= priorities[q.Bypass] {
+			continue
+		}
+		if end, ok := q.Until(now); ok {
+			return q, end, true
+		}
+	}
+	return nil, time.Time{}, false
+}
+
+// mentionPattern matches user, user group and broadcast mentions in mrkdwn.
+var mentionPattern = regexp.MustCompile(`<([@!])([^>|]+)(?:\|([^>]*))?>`)
+
+// silenceMentions replaces the mentions in text by plain text, so that the
+// message notifies nobody.
+func silenceMentions(text string) string {
+	return mentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		parts := mentionPattern.FindStringSubmatch(mention)
+		if parts[3] != "" {
+			return "@" + strings.TrimPrefix(parts[3], "@")
+		}
+		return "@" + strings.TrimPrefix(parts[2], "subteam^")
+	})
+}
+
+// silenced returns message with the mentions in its blocks, their fields
+// and the fallback text replaced by plain text.
+func silenced(message slack.Message) slack.Message {
+	message.Text = silenceMentions(message.Text)
+	blocks := make([]slack.Block, len(message.Blocks))
+	for i, block := range message.Blocks {
+		if block.Text != nil {
+			text := *block.Text
+			text.Text = silenceMentions(text.Text)
+			block.Text = &text
+		}
+		if block.Fields != nil {
+			fields := make([]slack.Field, len(block.Fields))
+			for j, field := range block.Fields {
+				field.Text = silenceMentions(field.Text)
+				fields[j] = field
+			}
+			block.Fields = fields
+		}
+		blocks[i] = block
+	}
+	message.Blocks = blocks
+	return message
+}
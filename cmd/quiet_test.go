@@ -0,0 +1,325 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+func TestQuietHoursUntil(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	at := func(value string) time.Time {
+		parsed, err := time.ParseInLocation("2006-01-02 15:04", value, berlin)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+	tests := []struct {
+		name     string
+		window   QuietHours
+		now      time.Time
+		expected time.Time
+		quiet    bool
+	}{
+		{
+			name:     "Overnight, before midnight",
+			window:   QuietHours{Timezone: "Europe/Berlin", Start: "22:00", End: "07:00"},
+			now:      at("2024-03-05 23:30"),
+			expected: at("2024-03-06 07:00"),
+			quiet:    true,
+		},
+		{
+			name:     "Overnight, after midnight",
+			window:   QuietHours{Timezone: "Europe/Berlin", Start: "22:00", End: "07:00"},
+			now:      at("2024-03-06 03:00"),
+			expected: at("2024-03-06 07:00"),
+			quiet:    true,
+		},
+		{
+			name:   "Overnight, at the end",
+			window: QuietHours{Timezone: "Europe/Berlin", Start: "22:00", End: "07:00"},
+			now:    at("2024-03-06 07:00"),
+		},
+		{
+			name:   "Overnight, daytime",
+			window: QuietHours{Timezone: "Europe/Berlin", Start: "22:00", End: "07:00"},
+			now:    at("2024-03-06 12:00"),
+		},
+		{
+			name:     "Same day",
+			window:   QuietHours{Timezone: "Europe/Berlin", Start: "12:00", End: "13:00"},
+			now:      at("2024-03-06 12:15"),
+			expected: at("2024-03-06 13:00"),
+			quiet:    true,
+		},
+		{
+			name:     "Other time zone",
+			window:   QuietHours{Timezone: "America/New_York", Start: "20:00", End: "08:00"},
+			now:      at("2024-03-06 03:00"),
+			expected: at("2024-03-06 14:00"),
+			quiet:    true,
+		},
+		{
+			name:     "Across a DST change",
+			window:   QuietHours{Timezone: "Europe/Berlin", Start: "22:00", End: "07:00"},
+			now:      at("2024-03-30 23:00"),
+			expected: at("2024-03-31 07:00"),
+			quiet:    true,
+		},
+		{
+			name:     "Weekend, started on Friday",
+			window:   QuietHours{Timezone: "Europe/Berlin", Start: "18:00", End: "08:00", Days: stringList{"fri", "sat", "sun"}},
+			now:      at("2024-03-09 01:00"),
+			expected: at("2024-03-09 08:00"),
+			quiet:    true,
+		},
+		{
+			name:   "Weekend, on a weekday",
+			window: QuietHours{Timezone: "Europe/Berlin", Start: "18:00", End: "08:00", Days: stringList{"fri", "sat", "sun"}},
+			now:    at("2024-03-06 19:00"),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.window.validate(); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			end, quiet := test.window.Until(test.now)
+			if quiet != test.quiet {
+				t.Fatalf("Expected quiet %v, got %v", test.quiet, quiet)
+			}
+			if quiet && !end.Equal(test.expected) {
+				t.Errorf("Expected the window to end at %s, got %s", test.expected, end)
+			}
+		})
+	}
+}
+
+func TestQuietHoursValidate(t *testing.T) {
+	tests := []struct {
+		window QuietHours
+		err    string
+	}{
+		{QuietHours{Start: "22:00", End: "07:00"}, "timezone is required"},
+		{QuietHours{Timezone: "Mars/Olympus", Start: "22:00", End: "07:00"}, "unknown timezone"},
+		{QuietHours{Timezone: "UTC", Start: "10pm", End: "07:00"}, "invalid quiet_hours start"},
+		{QuietHours{Timezone: "UTC", Start: "22:00", End: "22:00"}, "start and end"},
+		{QuietHours{Timezone: "UTC", Start: "22:00", End: "07:00", Days: stringList{"someday"}}, "invalid quiet_hours day"},
+		{QuietHours{Timezone: "UTC", Start: "22:00", End: "07:00", Action: "delay"}, "invalid quiet_hours action"},
+		{QuietHours{Timezone: "UTC", Start: "22:00", End: "07:00", Bypass: "urgent"}, "invalid quiet_hours bypass"},
+	}
+	for _, test := range tests {
+		err := test.window.validate()
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Expected an error containing %q, got %v", test.err, err)
+		}
+	}
+
+	window := QuietHours{Timezone: "UTC", Start: "22:00", End: "07:00", Days: stringList{"Monday"}}
+	if err := window.validate(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if window.Action != QuietSchedule || window.Bypass != PriorityNormal {
+		t.Errorf("Expected the defaults schedule and normal, got %s and %s", window.Action, window.Bypass)
+	}
+}
+
+func TestSilenceMentions(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"<!here> deploy failed", "@here deploy failed"},
+		{"cc <@U123> and <@U456|alice>", "cc @U123 and @alice"},
+		{"<!subteam^S123|@oncall> please look", "@oncall please look"},
+		{"<!subteam^S123> please look", "@S123 please look"},
+		{"see <https://example.com|the run>", "see <https://example.com|the run>"},
+	}
+	for _, test := range tests {
+		if got := silenceMentions(test.text); got != test.expected {
+			t.Errorf("silenceMentions(%q): expected %q, got %q", test.text, test.expected, got)
+		}
+	}
+}
+
+func TestSilenced(t *testing.T) {
+	message := slack.Message{
+		Text: "<!channel> deploy failed",
+		Blocks: []slack.Block{
+			{Type: slack.SectionBlock, Text: &slack.Text{Type: slack.Mrkdwn, Text: "<!here> deploy failed"}},
+			{Type: slack.SectionBlock, Fields: []slack.Field{{Type: slack.Mrkdwn, Text: "*Owner*\n<@U123|alice>"}}},
+		},
+	}
+	got := silenced(message)
+	if got.Text != "@channel deploy failed" {
+		t.Errorf("Expected the fallback text to be silenced, got %q", got.Text)
+	}
+	if got.Blocks[0].Text.Text != "@here deploy failed" {
+		t.Errorf("Expected the section text to be silenced, got %q", got.Blocks[0].Text.Text)
+	}
+	if got.Blocks[1].Fields[0].Text != "*Owner*\n@alice" {
+		t.Errorf("Expected the field to be silenced, got %q", got.Blocks[1].Fields[0].Text)
+	}
+	if message.Text != "<!channel> deploy failed" || message.Blocks[1].Fields[0].Text != "*Owner*\n<@U123|alice>" {
+		t.Error("Expected the original message to be left unchanged")
+	}
+}
+
+func TestPostQuietHours(t *testing.T) {
+	// 1700000000 is 2023-11-14 22:13:20 UTC.
+	setTimeNow(t, time.Unix(1700000000, 0))
+	window := func(action string) string {
+		return fmt.Sprintf("- channels: [general]\n  timezone: UTC\n  start: \"22:00\"\n  end: \"06:30\"\n  action: %s\n", action)
+	}
+	message := map[string]string{
+		"INPUT_TITLE":         "Nightly build failed",
+		"INPUT_TEXT":          "<!here> the nightly build failed",
+		"INPUT_SLACK_CHANNEL": "general",
+		"INPUT_PRIORITY":      PriorityLow,
+	}
+
+	t.Run("Schedule", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietSchedule))
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		requests := fake.requestsFor("chat.scheduleMessage")
+		if len(requests) != 1 {
+			t.Fatalf("Expected 1 call of chat.scheduleMessage, got %d", len(requests))
+		}
+		end := time.Date(2023, 11, 15, 6, 30, 0, 0, time.UTC).Unix()
+		if postAt := requests[0].param("post_at"); postAt != fmt.Sprint(end) {
+			t.Errorf("Expected post_at %d, got %s", end, postAt)
+		}
+		if !strings.Contains(outputs, "quiet_hours=schedule\n") || !strings.Contains(outputs, "scheduled_message_id=Q000001\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietDrop))
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if len(fake.requests) != 0 {
+			t.Errorf("Expected no Slack calls, got %d", len(fake.requests))
+		}
+		if !strings.Contains(outputs, "quiet_hours=drop\n") || strings.Contains(outputs, "delivered_via") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Silent", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietSilent))
+		if _, err := runWithFakeSlack(t, fake, message); err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		requests := fake.requestsFor("chat.postMessage")
+		if len(requests) != 1 {
+			t.Fatalf("Expected 1 call of chat.postMessage, got %d", len(requests))
+		}
+		if blocks := requests[0].param("blocks"); strings.Contains(blocks, "<!here>") || !strings.Contains(blocks, "@here the nightly build failed") {
+			t.Errorf("Expected the mention to be silenced, got %s", blocks)
+		}
+	})
+
+	t.Run("Normal priority by default", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietDrop))
+		t.Setenv("INPUT_PRIORITY", "")
+		outputs, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_TITLE":         message["INPUT_TITLE"],
+			"INPUT_TEXT":          message["INPUT_TEXT"],
+			"INPUT_SLACK_CHANNEL": "general",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 1 || !strings.Contains(outputs, "quiet_hours=none\n") {
+			t.Errorf("Expected a message without priority to be sent, got %d calls and outputs %q", calls, outputs)
+		}
+	})
+
+	t.Run("Deduplicated messages are not scheduled", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietSchedule))
+		env := map[string]string{"INPUT_DEDUPE_WINDOW": "24h", "INPUT_STATE_FILE": filepath.Join(t.TempDir(), "messages.json")}
+		for key, value := range message {
+			env[key] = value
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := runWithFakeSlack(t, fake, env); err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+		}
+		if calls := len(fake.requestsFor("chat.scheduleMessage")); calls != 0 {
+			t.Errorf("Expected nothing to be scheduled, got %d calls", calls)
+		}
+		requests := fake.requestsFor("chat.postMessage")
+		if len(requests) != 1 || strings.Contains(requests[0].param("blocks"), "<!here>") {
+			t.Errorf("Expected a single silent message, got %+v", requests)
+		}
+	})
+
+	t.Run("Critical bypasses", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietDrop))
+		t.Setenv("INPUT_PRIORITY", PriorityCritical)
+		outputs, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_TITLE":         message["INPUT_TITLE"],
+			"INPUT_TEXT":          message["INPUT_TEXT"],
+			"INPUT_SLACK_CHANNEL": "general",
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 1 {
+			t.Errorf("Expected 1 call of chat.postMessage, got %d", calls)
+		}
+		if !strings.Contains(outputs, "quiet_hours=none\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Other channel", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		fake.addChannel("C0OPS", "ops")
+		t.Setenv("INPUT_QUIET_HOURS", window(QuietDrop))
+		outputs, err := runWithFakeSlack(t, fake, map[string]string{
+			"INPUT_TITLE":         message["INPUT_TITLE"],
+			"INPUT_TEXT":          message["INPUT_TEXT"],
+			"INPUT_SLACK_CHANNEL": "general,ops",
+			"INPUT_PRIORITY":      PriorityLow,
+		})
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if messages := fake.channelMessages("C0OPS"); len(messages) != 1 {
+			t.Errorf("Expected 1 message in ops, got %d", len(messages))
+		}
+		if !strings.Contains(outputs, "quiet_hours=drop,none\n") || !strings.Contains(outputs, "channel=C0OPS\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Invalid priority", func(t *testing.T) {
+		if _, err := runWithFakeSlack(t, newFakeSlack(t), map[string]string{
+			"INPUT_TITLE":         "Deploy",
+			"INPUT_TEXT":          "Deploying",
+			"INPUT_SLACK_CHANNEL": "general",
+			"INPUT_PRIORITY":      "urgent",
+		}); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// statusReactions are the reactions added for a status when no reactions
+// are given explicitly.
+var statusReactions = map[string]string{
+	"success":   "white_check_mark",
+	"failure":   "x",
+	"cancelled": "no_entry_sign",
+}
+
+// AddReaction adds the emoji name to a message.
+func (w *webAPI) AddReaction(name string, item slack.MessageRef) error {
+	return w.call("reactions.add", reactionValues(name, item), nil)
+}
+
+// RemoveReaction removes the emoji name from a message.
+func (w *webAPI) RemoveReaction(name string, item slack.MessageRef) error {
+	return w.call("reactions.remove", reactionValues(name, item), nil)
+}
+
+func reactionValues(name string, item slack.MessageRef) url.Values {
+	return url.Values{
+		"name":      {name},
+		"channel":   {item.Channel},
+		"timestamp": {item.Timestamp},
+	}
+}
+
+// react adds and removes the configured reactions on the message identified
+// by INPUT_MESSAGE_TS. Without explicit reactions the status reaction is
+// added. Reactions that are already in the requested state count as done.
+func react() error {
+	item := slack.MessageRef{Channel: envVar.Slack.Channel, Timestamp: envVar.Slack.MessageTS}
+
+	add := envVar.Input.Reactions
+	if len(add) == 0 && len(envVar.Input.RemoveReactions) == 0 {
+		reaction, ok := statusReactions[envVar.Input.Status]
+		if !ok {
+			return fmt.Errorf("no reactions given and no reaction for status %q", envVar.Input.Status)
+		}
+		add = stringList{reaction}
+	}
+
+	for _, name := range envVar.Input.RemoveReactions {
+		name = emojiName(name)
+		if envVar.Input.DryRun {
+			logger.Infof("dry run: would remove :%s: from %s/%s", name, item.Channel, item.Timestamp)
+			continue
+		}
+		err := slackAPI.RemoveReaction(name, item)
+		if err != nil && !IsSlackError(err, "no_reaction") {
+			return fmt.Errorf("error while removing reaction %s: %v", name, err)
+		}
+	}
+	for _, name := range add {
+		name = emojiName(name)
+		if envVar.Input.DryRun {
+			logger.Infof("dry run: would add :%s: to %s/%s", name, item.Channel, item.Timestamp)
+			continue
+		}
+		err := slackAPI.AddReaction(name, item)
+		if err != nil && !IsSlackError(err, "already_reacted") {
+			return fmt.Errorf("error while adding reaction %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// emojiName strips the colons from an emoji written as :name:.
+func emojiName(name string) string {
+	return strings.Trim(strings.TrimSpace(name), ":")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// redactedMark replaces every secret found in a message.
+const redactedMark = "[REDACTED]"
+
+// minSecretLength keeps short values of secret variables, such as "true",
+// from being masked wherever they appear.
+const minSecretLength = 8
+
+// secretPatterns match credentials that are recognizable by their form.
+// When a pattern has a group only the group is masked.
+var secretPatterns = []*regexp.Regexp{
+	// AWS access key IDs and secret access keys.
+	regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`),
+	regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`),
+	// GitHub tokens: ghp_, gho_, ghu_, ghs_, ghr_ and fine-grained tokens.
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,255}\b`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{22,255}\b`),
+	// Slack tokens.
+	regexp.MustCompile(`\bxox[abposre]-[A-Za-z0-9-]{10,}`),
+	regexp.MustCompile(`\bxapp-[A-Za-z0-9-]{10,}`),
+	// JSON Web Tokens.
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	// PEM private keys, up to the end of the text when cut off.
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY(?: BLOCK)?-----.*?(?:-----END [A-Z ]*PRIVATE KEY(?: BLOCK)?-----|$)`),
+}
+
+// secretVariable matches the names of environment variables holding
+// secrets.
+var secretVariable = regexp.MustCompile(`(?i)TOKEN|SECRET|PASSWORD|PASSWD|API_?KEY|PRIVATE_?KEY|CREDENTIAL|WEBHOOK_URL`)
+
+// Redactor masks secrets in message text: the values of secret environment
+// variables, credentials recognizable by their form and user supplied
+// patterns. It counts what it masks.
+type Redactor struct {
+	secrets  []string
+	patterns []*regexp.Regexp
+	count    atomic.Int64
+}
+
+// NewRedactor returns a redactor masking secrets and anything matched by the
+// built-in patterns or patterns.
+func NewRedactor(secrets []string, patterns []string) (*Redactor, error) {
+	r := &Redactor{patterns: secretPatterns}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %v", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		secret = strings.TrimSpace(secret)
+		if len(secret) >= minSecretLength && !seen[secret] {
+			seen[secret] = true
+			r.secrets = append(r.secrets, secret)
+		}
+	}
+	// Longer secrets first, so that a secret containing another one is
+	// masked as a whole.
+	sort.Slice(r.secrets, func(i, j int) bool { return len(r.secrets[i]) > len(r.secrets[j]) })
+	return r, nil
+}
+
+// Redact returns text with the secrets masked.
+func (r *Redactor) Redact(text string) string {
+	if r == nil || text == "" {
+		return text
+	}
+	count := 0
+	for _, secret := range r.secrets {
+		if n := strings.Count(text, secret); n > 0 {
+			text = strings.ReplaceAll(text, secret, redactedMark)
+			count += n
+		}
+	}
+	for _, re := range r.patterns {
+		text = re.ReplaceAllStringFunc(text, func(match string) string {
+			count++
+			groups := re.FindStringSubmatchIndex(match)
+			if len(groups) < 4 || groups[2] < 0 {
+				return redactedMark
+			}
+			return match[:groups[2]] + redactedMark + match[groups[3]:]
+		})
+	}
+	r.count.Add(int64(count))
+	return text
+}
+
+// Count returns how many secrets were masked so far.
+func (r *Redactor) Count() int {
+	if r == nil {
+		return 0
+	}
+	return int(r.count.Load())
+}
+
+// secretValues returns the values of the environment variables that hold
+// secrets, and the Slack token.
+func secretValues(e *Environment) []string {
+	secrets := []string{e.Slack.Token, e.Fallback.SMTPPassword, e.Provider.WebhookURL}
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if secretVariable.MatchString(name) {
+			secrets = append(secrets, value)
+		}
+	}
+	return secrets
+}
+
+// redactor masks the secrets in every message built by SlackMessageBuilder
+// and FieldsBlocks.
+var redactor, _ = NewRedactor(nil, nil)
+
+// reportRedactions logs and outputs how many secrets were masked in the
+// message.
+func reportRedactions(count int) error {
+	if count > 0 {
+		logger.Warnf("%d secrets were redacted from the message", count)
+	}
+	return setOutput("redactions", fmt.Sprint(count))
+}
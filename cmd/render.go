@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// blockKitBuilderURL is the Block Kit Builder page; the blocks to preview are
+// passed URL-encoded in the fragment.
+const blockKitBuilderURL = "https://app.slack.com/block-kit-builder/#"
+
+// renderMessage writes the message that would be sent to Slack as JSON to w,
+// and appends it together with a Block Kit Builder preview link to the
+// GitHub step summary. Nothing is sent to Slack. metadata may be nil.
+func renderMessage(w io.Writer, message slack.Message, metadata *MessageMetadata) error {
+	payload, err := json.MarshalIndent(outgoingMessage{message, metadata}, "", "  ")
+	if err != nil {
+		return err
+	}
+	endGroup := logger.Group("Slack message payload")
+	_, err = fmt.Fprintln(w, string(payload))
+	endGroup()
+	if err != nil {
+		return err
+	}
+
+	previewURL, err := BlockKitBuilderURL(message)
+	if err != nil {
+		return err
+	}
+	logger.Noticef("dry run: message not sent, preview at %s", previewURL)
+	for _, problem := range ValidateBlockKit(payload) {
+		logger.Warnf("Slack would reject the message: %v", problem)
+	}
+
+	return appendStepSummary(renderSummary(payload, previewURL))
+}
+
+// BlockKitBuilderURL returns a link that opens the message blocks in Slack's
+// Block Kit Builder.
+func BlockKitBuilderURL(message slack.Message) (string, error) {
+	blocks, err := json.Marshal(struct {
+		Blocks []slack.Block `json:"blocks"`
+	}{Blocks: message.Blocks})
+	if err != nil {
+		return "", err
+	}
+	return blockKitBuilderURL + url.PathEscape(string(blocks)), nil
+}
+
+func renderSummary(payload []byte, previewURL string) string {
+	var b strings.Builder
+	b.WriteString("### Slack message (dry run)\n\n")
+	b.WriteString("```json\n")
+	b.Write(payload)
+	b.WriteString("\n```\n\n")
+	fmt.Fprintf(&b, "[Preview in Block Kit Builder](%s)\n", previewURL)
+	return b.String()
+}
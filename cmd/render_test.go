@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+func TestRenderMessage(t *testing.T) {
+	summary := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", summary)
+
+	message := SlackMessageBuilder("Deploy", "*done*", "deployments")
+
+	var out bytes.Buffer
+	if err := renderMessage(&out, message, nil); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	var rendered slack.Message
+	if err := json.Unmarshal(out.Bytes(), &rendered); err != nil {
+		t.Fatalf("Expected stdout to be the message JSON, got %q: %v", out.String(), err)
+	}
+	if rendered.Channel != "deployments" || len(rendered.Blocks) != 2 {
+		t.Errorf("Unexpected rendered message: %+v", rendered)
+	}
+
+	content, err := os.ReadFile(summary)
+	if err != nil {
+		t.Fatalf("Expected step summary to be written: %v", err)
+	}
+	if !strings.Contains(string(content), `"text": "*done*"`) {
+		t.Errorf("Expected step summary to contain the payload, got %s", content)
+	}
+	if !strings.Contains(string(content), blockKitBuilderURL) {
+		t.Errorf("Expected step summary to contain the preview link, got %s", content)
+	}
+}
+
+func TestBlockKitBuilderURL(t *testing.T) {
+	message := SlackMessageBuilder("Title with spaces", "Text & more", "test-channel")
+
+	previewURL, err := BlockKitBuilderURL(message)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if !strings.HasPrefix(previewURL, blockKitBuilderURL) {
+		t.Fatalf("Unexpected preview URL %s", previewURL)
+	}
+
+	fragment, err := url.PathUnescape(strings.TrimPrefix(previewURL, blockKitBuilderURL))
+	if err != nil {
+		t.Fatalf("Expected fragment to be escaped: %v", err)
+	}
+	var payload struct {
+		Blocks []slack.Block `json:"blocks"`
+	}
+	if err := json.Unmarshal([]byte(fragment), &payload); err != nil {
+		t.Fatalf("Expected fragment to be blocks JSON: %v", err)
+	}
+	if len(payload.Blocks) != 2 || payload.Blocks[0].Text.Text != "Title with spaces" {
+		t.Errorf("Unexpected blocks in preview: %+v", payload.Blocks)
+	}
+}
+
+func TestInitializeAppDryRun(t *testing.T) {
+	t.Setenv("INPUT_TITLE", "Title")
+	t.Setenv("INPUT_TEXT", "Text")
+	t.Setenv("INPUT_SLACK_CHANNEL", "test-channel")
+	t.Setenv("INPUT_DRY_RUN", "true")
+	t.Setenv("INPUT_SLACK_TOKEN", "")
+
+	if err := initializeApp(); err != nil {
+		t.Fatalf("Expected dry run to not require a token, got: %v", err)
+	}
+	if !envVar.Input.DryRun {
+		t.Error("Expected dry run to be enabled")
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"regexp"
+	"strconv"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// retryPolicy retries calls that failed with a transient error, waiting
+// twice as long before each attempt, or as long as Slack asks for when it
+// rate limits the call.
+type retryPolicy struct {
+	// Retries is the number of attempts after the first one.
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	sleep     func(time.Duration)
+}
+
+// retrySleep waits between attempts; tests replace it.
+var retrySleep = time.Sleep
+
+func newRetryPolicy(retries int) retryPolicy {
+	return retryPolicy{
+		Retries:   retries,
+		BaseDelay: time.Second,
+		MaxDelay:  30 * time.Second,
+		sleep:     retrySleep,
+	}
+}
+
+// do calls fn until it succeeds, fails with an error that is not transient,
+// or the retries are used up. It returns the last error.
+func (p retryPolicy) do(name string, fn func() error) error {
+	delay := p.BaseDelay
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Retries || !IsTransient(err) {
+			return err
+		}
+		wait := delay
+		var rateLimit *slack.ErrRateLimit
+		if errors.As(err, &rateLimit) && rateLimit.Value > 0 {
+			wait = rateLimit.Value
+		}
+		if wait > p.MaxDelay {
+			wait = p.MaxDelay
+		}
+		logger.Warnf("%s failed (attempt %d of %d), retrying in %s: %v", name, attempt+1, p.Retries+1, wait, err)
+		if p.sleep != nil {
+			p.sleep(wait)
+		}
+		delay *= 2
+	}
+}
+
+// transientSlackErrors are the error codes with which Slack reports that a
+// call may succeed when it is repeated.
+var transientSlackErrors = []string{
+	"ratelimited",
+	"internal_error",
+	"fatal_error",
+	"service_unavailable",
+	"request_timeout",
+}
+
+// serverStatus matches the error the Web API and the shared client return for
+// responses other than 200 OK.
+var serverStatus = regexp.MustCompile(`unexpected status code: (\d{3})`)
+
+// IsTransient reports whether err is worth retrying: rate limits, Slack
+// server errors and network failures.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rateLimit *slack.ErrRateLimit
+	if errors.As(err, &rateLimit) {
+		return true
+	}
+	if IsSlackError(err, transientSlackErrors...) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	if m := serverStatus.FindStringSubmatch(err.Error()); m != nil {
+		status, _ := strconv.Atoi(m[1])
+		return status >= 500
+	}
+	return false
+}
+
+// retryClient retries the posts and updates of the wrapped clients.
+type retryClient struct {
+	poster  messagePoster
+	updater messageUpdater
+	policy  retryPolicy
+}
+
+func (c retryClient) AddFormattedMessage(channel string, message slack.Message) (slack.MessageRef, error) {
+	var ref slack.MessageRef
+	err := c.policy.do("chat.postMessage", func() error {
+		var err error
+		ref, err = c.poster.AddFormattedMessage(channel, message)
+		return err
+	})
+	return ref, err
+}
+
+func (c retryClient) UpdateMessage(item slack.MessageRef, message slack.Message) error {
+	return c.policy.do("chat.update", func() error {
+		return c.updater.UpdateMessage(item, message)
+	})
+}
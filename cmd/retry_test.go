@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// noRetrySleep keeps the retry delays out of the test run.
+func noRetrySleep(t *testing.T) {
+	t.Helper()
+	original := retrySleep
+	retrySleep = func(time.Duration) {}
+	t.Cleanup(func() { retrySleep = original })
+}
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"rate limit", &slack.ErrRateLimit{Value: time.Second}, true},
+		{"wrapped rate limit", fmt.Errorf("error while sending: %w", &slack.ErrRateLimit{}), true},
+		{"internal error", &SlackError{Method: "chat.postMessage", Code: "internal_error"}, true},
+		{"service unavailable", &SlackError{Method: "chat.postMessage", Code: "service_unavailable"}, true},
+		{"channel not found", &SlackError{Method: "chat.postMessage", Code: "channel_not_found"}, false},
+		{"server error", errors.New("unexpected status code: 503"), true},
+		{"client error", errors.New("unexpected status code: 404"), false},
+		{"other", errors.New("invalid_auth"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsTransient(tt.err); got != tt.want {
+				t.Errorf("Expected IsTransient(%v) to be %v, got %v", tt.err, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIsTransientUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	api := newWebAPI(fakeSlackToken)
+	api.baseURL = "http://" + addr + "/api"
+	_, err = api.AuthTest()
+	if err == nil || !IsTransient(err) {
+		t.Errorf("Expected a transient error from auth.test, got %v", err)
+	}
+	_, err = api.AddFormattedMessage("C0GENERAL", slack.Message{Text: "Deploy"})
+	if err == nil || !IsTransient(err) {
+		t.Errorf("Expected a transient error from chat.postMessage, got %v", err)
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	internal := &SlackError{Method: "chat.postMessage", Code: "internal_error"}
+	tests := []struct {
+		name       string
+		retries    int
+		errs       []error
+		wantCalls  int
+		wantDelays []time.Duration
+		wantErr    bool
+	}{
+		{"success", 2, nil, 1, nil, false},
+		{"succeeds on retry", 3, []error{internal, internal}, 3, []time.Duration{time.Second, 2 * time.Second}, false},
+		{"honours retry after", 2, []error{&slack.ErrRateLimit{Value: 5 * time.Second}}, 2, []time.Duration{5 * time.Second}, false},
+		{"caps the delay", 1, []error{&slack.ErrRateLimit{Value: time.Hour}}, 2, []time.Duration{30 * time.Second}, false},
+		{"retries used up", 1, []error{internal, internal, internal}, 2, []time.Duration{time.Second}, true},
+		{"permanent error", 3, []error{&SlackError{Code: "channel_not_found"}}, 1, nil, true},
+		{"no retries", 0, []error{internal}, 1, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var delays []time.Duration
+			policy := newRetryPolicy(tt.retries)
+			policy.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+			calls := 0
+			err := policy.do("chat.postMessage", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if fmt.Sprint(delays) != fmt.Sprint(tt.wantDelays) {
+				t.Errorf("Expected delays %v, got %v", tt.wantDelays, delays)
+			}
+		})
+	}
+}
+
+func TestPostRetriesTransientErrors(t *testing.T) {
+	noRetrySleep(t)
+	message := map[string]string{
+		"INPUT_TITLE":         "Deploy",
+		"INPUT_TEXT":          "Deploying to production",
+		"INPUT_SLACK_CHANNEL": "general",
+		"INPUT_RETRIES":       "2",
+	}
+
+	t.Run("Recovers", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		fake.failNext("chat.postMessage", "ratelimited")
+		fake.failNext("chat.postMessage", "internal_error")
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 3 {
+			t.Errorf("Expected 3 calls of chat.postMessage, got %d", calls)
+		}
+		if !strings.Contains(outputs, "delivered_via=slack\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Permanent error", func(t *testing.T) {
+		fake := newFakeSlack(t)
+		fake.failNext("chat.postMessage", "not_in_channel")
+		if _, err := runWithFakeSlack(t, fake, message); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if calls := len(fake.requestsFor("chat.postMessage")); calls != 1 {
+			t.Errorf("Expected a single call of chat.postMessage, got %d", calls)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Route is a routing rule. The first route whose conditions all match the
+// current run decides where the message goes.
+type Route struct {
+	Name     string       `yaml:"name"`
+	Match    RouteMatch   `yaml:"match"`
+	Channels []string     `yaml:"channels"`
+	Profile  string       `yaml:"profile"`
+	Mention  MentionRules `yaml:"mention"`
+}
+
+// RouteMatch holds the conditions of a route. Each condition matches when
+// any of its values matches; empty conditions always match.
+type RouteMatch struct {
+	// Ref globs are matched against both the full ref and the ref name.
+	Ref    stringList `yaml:"ref"`
+	Event  stringList `yaml:"event"`
+	Status stringList `yaml:"status"`
+	Actor  stringList `yaml:"actor"`
+	// Paths globs match when any changed file matches; ** crosses
+	// directories.
+	Paths stringList `yaml:"paths"`
+}
+
+// Routes is a list of routes. As an input it is written as a YAML list using
+// the same schema as the routes section of the configuration file.
+type Routes []Route
+
+// UnmarshalEnvironmentValue implements env.Unmarshaler.
+func (r *Routes) UnmarshalEnvironmentValue(data string) error {
+	if strings.TrimSpace(data) == "" {
+		return nil
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(r); err != nil {
+		return fmt.Errorf("invalid routes: %v", err)
+	}
+	return nil
+}
+
+// RouteContext is what routes are matched against. ChangedFiles is only
+// called when a route has a paths condition.
+type RouteContext struct {
+	GitHub       GitHubContext
+	Status       string
+	ChangedFiles func() ([]string, error)
+}
+
+// MatchRoute returns the first route matching ctx. It reports false when no
+// route matches.
+func MatchRoute(routes Routes, ctx RouteContext) (Route, bool, error) {
+	var (
+		changed []string
+		loaded  bool
+	)
+	for _, route := range routes {
+		m := route.Match
+		if !matchAny(m.Ref, ctx.GitHub.Ref, ctx.GitHub.RefName) ||
+			!matchAny(m.Event, ctx.GitHub.EventName) ||
+			!matchAny(m.Status, ctx.Status) ||
+			!matchAny(m.Actor, ctx.GitHub.Actor) {
+			continue
+		}
+		if len(m.Paths) > 0 {
+			if !loaded {
+				files, err := ctx.ChangedFiles()
+				if err != nil {
+					return Route{}, false, fmt.Errorf("error listing changed files for route %s: %v", route.name(), err)
+				}
+				changed, loaded = files, true
+			}
+			if !matchAny(m.Paths, changed...) {
+				continue
+			}
+		}
+		return route, true, nil
+	}
+	return Route{}, false, nil
+}
+
+func (r Route) name() string {
+	if r.Name == "" {
+		return fmt.Sprintf("to %s", strings.Join(r.Channels, ","))
+	}
+	return r.Name
+}
+
+// matchAny reports whether any of the glob patterns matches any of the
+// values. An empty pattern list matches everything.
+func matchAny(patterns []string, values ...string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		for _, value := range values {
+			if globMatch(pattern, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// globMatch matches name against a glob where * and ? stay within a path
+// segment and ** matches across segments.
+func globMatch(pattern string, name string) bool {
+	var expr strings.Builder
+	expr.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				i++
+				// "**/" also matches no directory at all.
+				if i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	matched, err := regexp.MatchString(expr.String(), name)
+	return err == nil && matched
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testRoutes = `
+- name: prod-failures
+  match:
+    ref: main
+    status: failure
+    event: [push, workflow_dispatch]
+  channels: [prod-alerts]
+  profile: deploy
+- name: pr-failures
+  match:
+    event: pull_request
+    status: failure
+  channels: [dev]
+- name: docs
+  match:
+    paths: ["docs/**", "*.md"]
+  channels: [docs]
+`
+
+func TestMatchRoute(t *testing.T) {
+	var routes Routes
+	if err := routes.UnmarshalEnvironmentValue(testRoutes); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		ctx     RouteContext
+		want    string
+		matched bool
+	}{
+		{
+			name: "Failure on main",
+			ctx: RouteContext{
+				GitHub: GitHubContext{Ref: "refs/heads/main", RefName: "main", EventName: "push"},
+				Status: "failure",
+			},
+			want:    "prod-failures",
+			matched: true,
+		},
+		{
+			name: "Pull request failure",
+			ctx: RouteContext{
+				GitHub: GitHubContext{Ref: "refs/pull/1/merge", RefName: "1/merge", EventName: "pull_request"},
+				Status: "failure",
+			},
+			want:    "pr-failures",
+			matched: true,
+		},
+		{
+			name: "Docs change",
+			ctx: RouteContext{
+				GitHub:       GitHubContext{Ref: "refs/heads/feature", EventName: "push"},
+				Status:       "success",
+				ChangedFiles: func() ([]string, error) { return []string{"docs/guide/setup.md"}, nil },
+			},
+			want:    "docs",
+			matched: true,
+		},
+		{
+			name: "Success on main goes nowhere",
+			ctx: RouteContext{
+				GitHub:       GitHubContext{Ref: "refs/heads/main", RefName: "main", EventName: "push"},
+				Status:       "success",
+				ChangedFiles: func() ([]string, error) { return []string{"cmd/cmd.go"}, nil },
+			},
+			matched: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route, ok, err := MatchRoute(routes, tt.ctx)
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if ok != tt.matched {
+				t.Fatalf("Expected matched=%v, got %v", tt.matched, ok)
+			}
+			if ok && route.Name != tt.want {
+				t.Errorf("Expected route %s, got %s", tt.want, route.Name)
+			}
+		})
+	}
+}
+
+func TestMatchRouteChangedFilesError(t *testing.T) {
+	routes := Routes{{Name: "paths", Match: RouteMatch{Paths: stringList{"src/**"}}}}
+	_, _, err := MatchRoute(routes, RouteContext{
+		ChangedFiles: func() ([]string, error) { return nil, errors.New("no history") },
+	})
+	if err == nil {
+		t.Error("Expected error when changed files cannot be listed")
+	}
+}
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"main", "main", true},
+		{"release/*", "release/1.2", true},
+		{"release/*", "release/1.2/hotfix", false},
+		{"refs/heads/**", "refs/heads/feature/a", true},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/cmd.go", true},
+		{"docs/**", "docs/a/b.md", true},
+		{"*.md", "docs/a.md", false},
+		{"v?.0", "v1.0", true},
+		{"a.b", "axb", false},
+	}
+
+	for _, tt := range tests {
+		if got := globMatch(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("globMatch(%q, %q) = %v, expected %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestInitializeAppRouting(t *testing.T) {
+	setProfileEnv(t, writeTestConfig(t, testConfig), "")
+	t.Setenv("INPUT_ROUTES", testRoutes)
+	t.Setenv("INPUT_STATUS", "failure")
+	t.Setenv("GITHUB_REF", "refs/heads/main")
+	t.Setenv("GITHUB_EVENT_NAME", "push")
+
+	if err := initializeApp(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if envVar.Skip || envVar.Route != "prod-failures" {
+		t.Fatalf("Expected prod-failures route, got %q (skip=%v)", envVar.Route, envVar.Skip)
+	}
+	if envVar.Slack.Channel != "prod-alerts" {
+		t.Errorf("Expected route channel, got %q", envVar.Slack.Channel)
+	}
+	if envVar.Input.Title != "failure: deploy of pal-paul/message-slack" {
+		t.Errorf("Expected route profile to be applied, got title %q", envVar.Input.Title)
+	}
+}
+
+func TestInitializeAppRoutingKeepsExplicitChannel(t *testing.T) {
+	setProfileEnv(t, writeTestConfig(t, testConfig), "")
+	t.Setenv("INPUT_ROUTES", testRoutes)
+	t.Setenv("INPUT_STATUS", "failure")
+	t.Setenv("INPUT_SLACK_CHANNEL", "oncall")
+	t.Setenv("GITHUB_REF", "refs/heads/main")
+	t.Setenv("GITHUB_EVENT_NAME", "push")
+
+	if err := initializeApp(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if envVar.Route != "prod-failures" {
+		t.Fatalf("Expected prod-failures route, got %q", envVar.Route)
+	}
+	if envVar.Slack.Channel != "oncall" {
+		t.Errorf("Expected the channel input to win over the route and its profile, got %q", envVar.Slack.Channel)
+	}
+	if envVar.Input.Title != "failure: deploy of pal-paul/message-slack" {
+		t.Errorf("Expected route profile to be applied, got title %q", envVar.Input.Title)
+	}
+}
+
+func TestInitializeAppRoutingUnmatched(t *testing.T) {
+	setProfileEnv(t, writeTestConfig(t, testConfig), "")
+	t.Setenv("INPUT_ROUTES", testRoutes)
+	t.Setenv("GITHUB_REF", "refs/heads/main")
+	t.Setenv("GITHUB_EVENT_NAME", "push")
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	if err := os.WriteFile(eventPath, []byte(`{"commits":[{"modified":["cmd/cmd.go"]}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GITHUB_EVENT_PATH", eventPath)
+
+	if err := initializeApp(); err != nil {
+		t.Fatalf("Expected unmatched runs to not fail, got: %v", err)
+	}
+	if !envVar.Skip {
+		t.Error("Expected the run to be skipped")
+	}
+}
+
+func TestChangedFilesFromPushEvent(t *testing.T) {
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	payload := `{"commits":[{"added":["a.go"],"modified":["b.go"]},{"removed":["a.go"],"modified":["c.go"]}]}`
+	if err := os.WriteFile(eventPath, []byte(payload), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := GitHubContext{EventPath: eventPath}.ChangedFiles()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(files) != 3 {
+		t.Errorf("Expected 3 unique files, got %v", files)
+	}
+}
+
+func TestChangedFilesFromPullRequest(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	write := func(name string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	git("init", "-q")
+	write("README.md")
+	git("add", "-A")
+	git("commit", "-qm", "base")
+	base := git("rev-parse", "HEAD")
+	write("docs/release notes.md")
+	write("docs/ünïcode.md")
+	git("add", "-A")
+	git("commit", "-qm", "head")
+	head := git("rev-parse", "HEAD")
+
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	payload := fmt.Sprintf(`{"pull_request":{"base":{"sha":%q},"head":{"sha":%q}}}`, base, head)
+	if err := os.WriteFile(eventPath, []byte(payload), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := GitHubContext{EventPath: eventPath, Workspace: dir}.ChangedFiles()
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	expected := []string{"docs/release notes.md", "docs/ünïcode.md"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected %q, got %q", expected, files)
+	}
+
+	payload = fmt.Sprintf(`{"pull_request":{"base":{"sha":%q},"head":{"sha":%q}}}`, head, head)
+	if err := os.WriteFile(eventPath, []byte(payload), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if files, err := (GitHubContext{EventPath: eventPath, Workspace: dir}).ChangedFiles(); err != nil || len(files) != 0 {
+		t.Errorf("Expected no files for an empty diff, got %q, %v", files, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// maxScheduleAhead is how far in the future Slack accepts scheduled
+// messages.
+const maxScheduleAhead = 120 * 24 * time.Hour
+
+// ParsePostAt parses post_at, either an RFC3339 time or a duration relative
+// to now such as "+2h", "+90m" or "+3d". The time must be in the future and
+// at most 120 days ahead.
+func ParsePostAt(value string, now time.Time) (time.Time, error) {
+	var postAt time.Time
+	if rel, ok := strings.CutPrefix(value, "+"); ok {
+		d, err := parseRelativeDuration(rel)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid post_at %q: %v", value, err)
+		}
+		postAt = now.Add(d)
+	} else {
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid post_at %q: expected RFC3339 or +duration", value)
+		}
+		postAt = t
+	}
+
+	if !postAt.After(now) {
+		return time.Time{}, fmt.Errorf("invalid post_at %q: %s is in the past", value, postAt.Format(time.RFC3339))
+	}
+	if postAt.Sub(now) > maxScheduleAhead {
+		return time.Time{}, fmt.Errorf("invalid post_at %q: messages can be scheduled at most 120 days ahead", value)
+	}
+	return postAt, nil
+}
+
+// parseRelativeDuration extends time.ParseDuration with a d suffix for days.
+func parseRelativeDuration(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// ScheduleMessage schedules message for postAt and returns the scheduled
+// message ID. metadata may be nil.
+func (w *webAPI) ScheduleMessage(channel string, message slack.Message, metadata *MessageMetadata, postAt time.Time) (string, error) {
+	message.Channel = channel
+	var response struct {
+		ScheduledMessageID string `json:"scheduled_message_id"`
+	}
+	err := w.callJSON("chat.scheduleMessage", struct {
+		outgoingMessage
+		PostAt int64 `json:"post_at"`
+	}{outgoingMessage{message, metadata}, postAt.Unix()}, &response)
+	if err != nil {
+		return "", err
+	}
+	return response.ScheduledMessageID, nil
+}
+
+// DeleteScheduledMessage cancels a scheduled message before it is posted.
+func (w *webAPI) DeleteScheduledMessage(channel string, id string) error {
+	return w.callJSON("chat.deleteScheduledMessage", map[string]string{
+		"channel":              channel,
+		"scheduled_message_id": id,
+	}, nil)
+}
+
+// cancel deletes the message identified by INPUT_SCHEDULED_MESSAGE_ID.
+func cancel() error {
+	if envVar.Input.DryRun {
+		logger.Infof("dry run: would cancel scheduled message %s in %s", envVar.Slack.ScheduledMessageID, envVar.Slack.Channel)
+		return nil
+	}
+	err := slackAPI.DeleteScheduledMessage(envVar.Slack.Channel, envVar.Slack.ScheduledMessageID)
+	if err != nil {
+		return fmt.Errorf("error while cancelling scheduled message: %v", err)
+	}
+	return nil
+}
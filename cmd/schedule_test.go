@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePostAt(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr string
+	}{
+		{name: "Relative hours", value: "+2h", want: now.Add(2 * time.Hour)},
+		{name: "Relative minutes", value: "+90m", want: now.Add(90 * time.Minute)},
+		{name: "Relative days", value: "+3d", want: now.Add(72 * time.Hour)},
+		{name: "RFC3339", value: "2026-03-02T09:30:00+01:00", want: time.Date(2026, 3, 2, 8, 30, 0, 0, time.UTC)},
+		{name: "Past time", value: "2026-03-01T11:59:00Z", wantErr: "in the past"},
+		{name: "Now", value: "+0s", wantErr: "in the past"},
+		{name: "Beyond 120 days", value: "+121d", wantErr: "at most 120 days"},
+		{name: "Exactly 120 days", value: "+120d", want: now.Add(maxScheduleAhead)},
+		{name: "Garbage", value: "tomorrow", wantErr: "expected RFC3339"},
+		{name: "Bad duration", value: "+2x", wantErr: "invalid post_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePostAt(tt.value, now)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestScheduleMessage(t *testing.T) {
+	var body map[string]any
+	slackAPI = newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.scheduleMessage" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		content, _ := io.ReadAll(r.Body)
+		json.Unmarshal(content, &body)
+		w.Write([]byte(`{"ok":true,"channel":"C0RELEASE","scheduled_message_id":"Q1298393284","post_at":1772370000}`))
+	})
+	outputs := filepath.Join(t.TempDir(), "outputs")
+	t.Setenv("GITHUB_OUTPUT", outputs)
+
+	envVar = Environment{}
+	envVar.Input.Title = "Code freeze"
+	envVar.Input.Text = "Code freeze in 1 hour"
+	envVar.Slack.Channel = "release-train"
+	envVar.Input.ScheduledAt = time.Unix(1772370000, 0)
+
+	if err := run(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if body["post_at"] != float64(1772370000) || body["channel"] != "release-train" {
+		t.Errorf("Unexpected request body %v", body)
+	}
+	if blocks, _ := body["blocks"].([]any); len(blocks) != 2 {
+		t.Errorf("Expected the message blocks to be scheduled, got %v", body["blocks"])
+	}
+
+	content, err := os.ReadFile(outputs)
+	if err != nil {
+		t.Fatalf("Expected outputs to be written: %v", err)
+	}
+	if !strings.Contains(string(content), "scheduled_message_id=Q1298393284\n") {
+		t.Errorf("Expected scheduled_message_id output, got %q", content)
+	}
+}
+
+func TestCancelScheduledMessage(t *testing.T) {
+	var body map[string]string
+	slackAPI = newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.deleteScheduledMessage" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		content, _ := io.ReadAll(r.Body)
+		json.Unmarshal(content, &body)
+		if body["scheduled_message_id"] == "Q0MISSING" {
+			w.Write([]byte(`{"ok":false,"error":"invalid_scheduled_message_id"}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	envVar = Environment{}
+	envVar.Operation = OperationCancel
+	envVar.Slack.Channel = "C0RELEASE"
+	envVar.Slack.ScheduledMessageID = "Q1298393284"
+	if err := run(); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if body["channel"] != "C0RELEASE" || body["scheduled_message_id"] != "Q1298393284" {
+		t.Errorf("Unexpected request body %v", body)
+	}
+
+	envVar.Slack.ScheduledMessageID = "Q0MISSING"
+	if err := run(); err == nil || !strings.Contains(err.Error(), "invalid_scheduled_message_id") {
+		t.Errorf("Expected invalid_scheduled_message_id error, got %v", err)
+	}
+}
+
+func TestInitializeAppRejectsPastPostAt(t *testing.T) {
+	SetupTestEnvironment()
+	defer CleanupTestEnvironment()
+	t.Setenv("INPUT_POST_AT", "2000-01-01T00:00:00Z")
+
+	if err := initializeApp(); err == nil || !strings.Contains(err.Error(), "in the past") {
+		t.Errorf("Expected past post_at to be rejected, got %v", err)
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	outputs := filepath.Join(t.TempDir(), "outputs")
+	t.Setenv("GITHUB_OUTPUT", outputs)
+
+	if err := setOutput("ts", "1700000000.000100"); err != nil {
+		t.Fatal(err)
+	}
+	if err := setOutput("text", "line one\nline two"); err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(outputs)
+	lines := strings.Split(string(content), "\n")
+	if lines[0] != "ts=1700000000.000100" {
+		t.Errorf("Unexpected single line output %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "text<<") || lines[2] != "line one" || lines[3] != "line two" || lines[4] != strings.TrimPrefix(lines[1], "text<<") {
+		t.Errorf("Unexpected multi-line output %q", content)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+	"gopkg.in/yaml.v3"
+)
+
+// commandServe is the subcommand that runs the HTTP relay.
+const commandServe = "serve"
+
+const (
+	// defaultServerListen is the address the relay listens on.
+	defaultServerListen = ":8080"
+	// defaultServerRetries is how often the relay retries transient
+	// Slack errors.
+	defaultServerRetries = 3
+	// maxRequestBody limits the size of request bodies.
+	maxRequestBody = 1 << 20
+	// maxSignatureAge is how old a signed request may be, which limits
+	// replays.
+	maxSignatureAge = 5 * time.Minute
+	// shutdownTimeout is how long requests in flight may take once the
+	// relay is asked to stop.
+	shutdownTimeout = 10 * time.Second
+)
+
+// Headers of signed requests. The signature is the hex encoded HMAC-SHA256
+// of the timestamp, a dot and the body, prefixed with sha256=.
+const (
+	headerSignature = "X-Signature"
+	headerTimestamp = "X-Timestamp"
+	headerAPIKey    = "X-API-Key"
+)
+
+// ServerConfig configures the relay started by the serve subcommand.
+type ServerConfig struct {
+	Listen string `yaml:"listen"`
+	// Channel is used by requests that do not name one.
+	Channel string `yaml:"channel"`
+	// Retries overrides defaultServerRetries.
+	Retries *int          `yaml:"retries"`
+	Clients []RelayClient `yaml:"clients"`
+	// Alertmanager enables POST /v1/alertmanager.
+	Alertmanager *AlertmanagerConfig `yaml:"alertmanager"`
+	// Hooks are served at POST /hooks/{name}.
+	Hooks map[string]*HookConfig `yaml:"hooks"`
+	// Redact are regular expressions masked in messages in addition to
+	// the built-in secret patterns.
+	Redact []string `yaml:"redact"`
+}
+
+// RelayClient is a caller of the relay. It authenticates with APIKey, sent
+// as a bearer token or X-API-Key header, or signs its requests with
+// HMACSecret. Both values may refer to environment variables as $NAME or
+// ${NAME}, so that the file holds no secrets.
+type RelayClient struct {
+	Name       string `yaml:"name"`
+	APIKey     string `yaml:"api_key"`
+	HMACSecret string `yaml:"hmac_secret"`
+	// Channels restricts the channels the client may post to.
+	Channels stringList `yaml:"channels"`
+}
+
+// LoadServerConfig reads and validates a relay configuration file.
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	var cfg ServerConfig
+	if err := decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("invalid server config %s: %v", path, err)
+	}
+	if cfg.Listen == "" {
+		cfg.Listen = defaultServerListen
+	}
+	if cfg.Retries == nil {
+		retries := defaultServerRetries
+		cfg.Retries = &retries
+	}
+	names := map[string]bool{}
+	for i := range cfg.Clients {
+		client := &cfg.Clients[i]
+		client.APIKey = os.ExpandEnv(client.APIKey)
+		client.HMACSecret = os.ExpandEnv(client.HMACSecret)
+		switch {
+		case client.Name == "":
+			return nil, fmt.Errorf("invalid server config %s: client %d has no name", path, i+1)
+		case names[client.Name]:
+			return nil, fmt.Errorf("invalid server config %s: client %s is defined twice", path, client.Name)
+		case client.APIKey == "" && client.HMACSecret == "":
+			return nil, fmt.Errorf("invalid server config %s: client %s needs an api_key or hmac_secret", path, client.Name)
+		}
+		names[client.Name] = true
+	}
+	if len(cfg.Clients) == 0 {
+		return nil, fmt.Errorf("invalid server config %s: no clients configured", path)
+	}
+	for name, hook := range cfg.Hooks {
+		if err := hook.validate(name); err != nil {
+			return nil, fmt.Errorf("invalid server config %s: %v", path, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// allows reports whether the client may post to channel.
+func (c *RelayClient) allows(channel string) bool {
+	if len(c.Channels) == 0 {
+		return true
+	}
+	for _, allowed := range c.Channels {
+		if strings.TrimPrefix(allowed, "#") == strings.TrimPrefix(channel, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+// relayMessage is the body of POST /v1/messages.
+type relayMessage struct {
+	Title   string  `json:"title"`
+	Text    string  `json:"text"`
+	Channel string  `json:"channel"`
+	Fields  Fields  `json:"fields"`
+	Buttons Buttons `json:"buttons"`
+}
+
+// relayResponse is the body of every relay response.
+type relayResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	TS      string `json:"ts,omitempty"`
+}
+
+// relayServer posts the messages it receives over HTTP to Slack, so that
+// systems outside GitHub can notify without holding the token.
+type relayServer struct {
+	config *ServerConfig
+	poster messagePoster
+	alerts *alertReceiver
+	// now is the clock signatures are checked against.
+	now func() time.Time
+}
+
+// newRelayServer creates a relay that posts through api, retrying
+// transient errors as configured.
+func newRelayServer(config *ServerConfig, api *webAPI) *relayServer {
+	client := retryClient{poster: api, updater: api, policy: newRetryPolicy(*config.Retries)}
+	s := &relayServer{
+		config: config,
+		poster: client,
+		now:    time.Now,
+	}
+	if config.Alertmanager != nil {
+		s.alerts = newAlertReceiver(config.Alertmanager, config.Channel, client, client)
+	}
+	return s
+}
+
+// Handler returns the routes of the relay.
+func (s *relayServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("POST /v1/messages", s.handleMessage)
+	if s.alerts != nil {
+		mux.HandleFunc("POST /v1/alertmanager", s.handleAlertmanager)
+	}
+	if len(s.config.Hooks) > 0 {
+		mux.HandleFunc("POST /hooks/{name}", s.handleHook)
+	}
+	return mux
+}
+
+func (s *relayServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeRelayResponse(w, http.StatusOK, relayResponse{OK: true})
+}
+
+func (s *relayServer) handleMessage(w http.ResponseWriter, r *http.Request) {
+	body, client, ok := s.authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var request relayMessage
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeRelayError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if request.Channel == "" {
+		request.Channel = s.config.Channel
+	}
+	switch {
+	case request.Title == "" || request.Text == "":
+		writeRelayError(w, http.StatusBadRequest, "title and text are required")
+		return
+	case request.Channel == "":
+		writeRelayError(w, http.StatusBadRequest, "channel is required")
+		return
+	case !client.allows(request.Channel):
+		writeRelayError(w, http.StatusForbidden, fmt.Sprintf("client %s may not post to %s", client.Name, request.Channel))
+		return
+	}
+
+	s.send(w, "client "+client.Name, request.Channel, Notification{
+		Title:   request.Title,
+		Text:    request.Text,
+		Fields:  request.Fields,
+		Buttons: request.Buttons,
+	})
+}
+
+// send builds the message for n, posts it to channel and writes the
+// response. source names the sender in the log.
+func (s *relayServer) send(w http.ResponseWriter, source string, channel string, n Notification) {
+	ref, err := s.poster.AddFormattedMessage(channel, notificationMessage(channel, n))
+	if err != nil {
+		logger.Errorf("%s: error while sending message to %s: %v", source, channel, err)
+		writeRelayError(w, relayErrorStatus(err), err.Error())
+		return
+	}
+	logger.Infof("%s posted %s to %s", source, ref.Timestamp, ref.Channel)
+	writeRelayResponse(w, http.StatusOK, relayResponse{OK: true, Channel: ref.Channel, TS: ref.Timestamp})
+}
+
+// notificationMessage builds the Slack message the relay posts for n.
+func notificationMessage(channel string, n Notification) slack.Message {
+	message := SlackMessageBuilder(n.Title, n.Text, channel)
+	message.Blocks = append(message.Blocks, FieldsBlocks(n.Fields)...)
+	message.Blocks = append(message.Blocks, ButtonsBlocks(n.Buttons)...)
+	return message
+}
+
+// authenticate reads the request body and finds the client that sent it.
+// It writes the error response itself when the request is rejected.
+func (s *relayServer) authenticate(w http.ResponseWriter, r *http.Request) ([]byte, *RelayClient, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	if err != nil {
+		writeRelayError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		return nil, nil, false
+	}
+
+	if signature := r.Header.Get(headerSignature); signature != "" {
+		client, err := s.verifySignature(signature, r.Header.Get(headerTimestamp), body)
+		if err != nil {
+			writeRelayError(w, http.StatusUnauthorized, err.Error())
+			return nil, nil, false
+		}
+		return body, client, true
+	}
+
+	key := r.Header.Get(headerAPIKey)
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = bearer
+	}
+	if key != "" {
+		for i := range s.config.Clients {
+			client := &s.config.Clients[i]
+			if client.APIKey != "" && subtle.ConstantTimeCompare([]byte(client.APIKey), []byte(key)) == 1 {
+				return body, client, true
+			}
+		}
+	}
+	writeRelayError(w, http.StatusUnauthorized, "invalid or missing credentials")
+	return nil, nil, false
+}
+
+// verifySignature returns the client whose secret produced signature.
+func (s *relayServer) verifySignature(signature string, timestamp string, body []byte) (*RelayClient, error) {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid or missing %s", headerTimestamp)
+	}
+	if age := s.now().Sub(time.Unix(seconds, 0)); age > maxSignatureAge || age < -maxSignatureAge {
+		return nil, fmt.Errorf("request timestamp is too far from the current time")
+	}
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", headerSignature)
+	}
+	for i := range s.config.Clients {
+		client := &s.config.Clients[i]
+		if client.HMACSecret != "" && hmac.Equal(got, SignRequest(client.HMACSecret, timestamp, body)) {
+			return client, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid signature")
+}
+
+// SignRequest returns the HMAC-SHA256 of timestamp, a dot and body, the
+// signature the relay expects in X-Signature.
+func SignRequest(secret string, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// relayErrorStatus maps a Slack error to the status of the relay response:
+// transient errors are reported as unavailable, the rest as bad gateway.
+func relayErrorStatus(err error) int {
+	if IsTransient(err) {
+		return http.StatusServiceUnavailable
+	}
+	if IsSlackError(err, "channel_not_found", "not_in_channel", "is_archived") {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusBadGateway
+}
+
+func writeRelayError(w http.ResponseWriter, status int, message string) {
+	writeRelayResponse(w, status, relayResponse{Error: message})
+}
+
+func writeRelayResponse(w http.ResponseWriter, status int, response relayResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// runServer serves handler on listener until ctx is done, then shuts down
+// gracefully, letting requests in flight finish.
+func runServer(ctx context.Context, listener net.Listener, handler http.Handler) error {
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	logger.Infof("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error while shutting down: %v", err)
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// serve runs the relay with the command line arguments that follow the
+// serve subcommand. The Slack token is read from SLACK_TOKEN.
+func serve(args []string) error {
+	flags := flag.NewFlagSet(commandServe, flag.ContinueOnError)
+	configPath := flags.String("config", "relay.yml", "relay configuration file")
+	listen := flags.String("listen", "", "address to listen on, overriding the configuration file")
+	logLevel := flags.String("log-level", LevelInfo, "lowest level logged: debug, info, notice, warning or error")
+	logFormat := flags.String("log-format", LogFormatText, "log format: text or json")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if err := configureLogger(*logLevel, *logFormat); err != nil {
+		return err
+	}
+
+	config, err := LoadServerConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	if *listen != "" {
+		config.Listen = *listen
+	}
+	token := os.Getenv("SLACK_TOKEN")
+	if token == "" {
+		return fmt.Errorf("SLACK_TOKEN is required")
+	}
+	logger.Mask(token)
+	if redactor, err = NewRedactor([]string{token}, config.Redact); err != nil {
+		return err
+	}
+	api := newWebAPI(token)
+	if apiURL := os.Getenv(envSlackAPIURL); apiURL != "" {
+		api.baseURL = strings.TrimSuffix(apiURL, "/")
+	}
+
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return err
+	}
+	logger.Infof("listening on %s", listener.Addr())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return runServer(ctx, listener, newRelayServer(config, api).Handler())
+}
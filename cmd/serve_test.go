@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testServerConfig() *ServerConfig {
+	retries := 1
+	return &ServerConfig{
+		Listen:  defaultServerListen,
+		Channel: "general",
+		Retries: &retries,
+		Clients: []RelayClient{
+			{Name: "jenkins", APIKey: "jenkins-key"},
+			{Name: "argo", HMACSecret: "argo-secret", Channels: stringList{"general"}},
+			{Name: "cron", APIKey: "cron-key", Channels: stringList{"#ops"}},
+		},
+	}
+}
+
+// newTestRelay starts the relay with config in front of a fake Slack server.
+func newTestRelay(t *testing.T, config *ServerConfig) (*httptest.Server, *fakeSlack) {
+	t.Helper()
+	noRetrySleep(t)
+	fake := newFakeSlack(t)
+	api := newWebAPI(fakeSlackToken)
+	api.baseURL = fake.URL
+	server := httptest.NewServer(newRelayServer(config, api).Handler())
+	t.Cleanup(server.Close)
+	return server, fake
+}
+
+func relayRequest(t *testing.T, server *httptest.Server, body string, headers map[string]string) (int, relayResponse) {
+	t.Helper()
+	return relayPost(t, server.URL+"/v1/messages", body, headers)
+}
+
+func relayPost(t *testing.T, url string, body string, headers map[string]string) (int, relayResponse) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Expected a response, got %v", err)
+	}
+	defer resp.Body.Close()
+	var response relayResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("Expected a JSON response, got %v", err)
+	}
+	return resp.StatusCode, response
+}
+
+func signedHeaders(secret string, at time.Time, body string) map[string]string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return map[string]string{
+		headerTimestamp: timestamp,
+		headerSignature: "sha256=" + hex.EncodeToString(SignRequest(secret, timestamp, []byte(body))),
+	}
+}
+
+func TestRelayMessages(t *testing.T) {
+	const body = `{"title":"Backup done","text":"*42* GB","fields":[{"title":"Host","value":"db1"}]}`
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		body       string
+		headers    map[string]string
+		wantStatus int
+		wantError  string
+	}{
+		{"API key header", body, map[string]string{headerAPIKey: "jenkins-key"}, http.StatusOK, ""},
+		{"Bearer token", body, map[string]string{"Authorization": "Bearer jenkins-key"}, http.StatusOK, ""},
+		{"Signature", body, signedHeaders("argo-secret", now, body), http.StatusOK, ""},
+		{"No credentials", body, nil, http.StatusUnauthorized, "credentials"},
+		{"Wrong API key", body, map[string]string{headerAPIKey: "nope"}, http.StatusUnauthorized, "credentials"},
+		{"Wrong secret", body, signedHeaders("nope", now, body), http.StatusUnauthorized, "invalid signature"},
+		{"Old signature", body, signedHeaders("argo-secret", now.Add(-time.Hour), body), http.StatusUnauthorized, "timestamp"},
+		{"Tampered body", body, signedHeaders("argo-secret", now, `{"title":"x","text":"y"}`), http.StatusUnauthorized, "invalid signature"},
+		{"Missing text", `{"title":"Backup done"}`, map[string]string{headerAPIKey: "jenkins-key"}, http.StatusBadRequest, "required"},
+		{"Unknown field", `{"title":"a","text":"b","colour":"red"}`, map[string]string{headerAPIKey: "jenkins-key"}, http.StatusBadRequest, "invalid request"},
+		{"Channel not allowed", body, map[string]string{headerAPIKey: "cron-key"}, http.StatusForbidden, "may not post"},
+		{"Unknown channel", `{"title":"a","text":"b","channel":"missing"}`, map[string]string{headerAPIKey: "jenkins-key"}, http.StatusUnprocessableEntity, "channel_not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, fake := newTestRelay(t, testServerConfig())
+			status, response := relayRequest(t, server, tt.body, tt.headers)
+			if status != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %+v", tt.wantStatus, status, response)
+			}
+			if tt.wantError != "" {
+				if response.OK || !strings.Contains(response.Error, tt.wantError) {
+					t.Errorf("Expected error containing %q, got %+v", tt.wantError, response)
+				}
+				return
+			}
+			if !response.OK || response.Channel != "C0GENERAL" || response.TS == "" {
+				t.Errorf("Unexpected response %+v", response)
+			}
+			messages := fake.channelMessages("C0GENERAL")
+			if len(messages) != 1 || messages[0].TS != response.TS {
+				t.Errorf("Expected the message to be posted as %s, got %+v", response.TS, messages)
+			}
+		})
+	}
+}
+
+func TestRelayRetries(t *testing.T) {
+	server, fake := newTestRelay(t, testServerConfig())
+	headers := map[string]string{headerAPIKey: "jenkins-key"}
+
+	fake.failNext("chat.postMessage", "internal_error")
+	status, response := relayRequest(t, server, `{"title":"a","text":"b"}`, headers)
+	if status != http.StatusOK || !response.OK {
+		t.Fatalf("Expected the retry to succeed, got %d %+v", status, response)
+	}
+
+	fake.failNext("chat.postMessage", "internal_error")
+	fake.failNext("chat.postMessage", "internal_error")
+	status, response = relayRequest(t, server, `{"title":"a","text":"b"}`, headers)
+	if status != http.StatusServiceUnavailable || response.OK {
+		t.Errorf("Expected 503 once the retries are used up, got %d %+v", status, response)
+	}
+}
+
+func TestRelayHealth(t *testing.T) {
+	server, _ := newTestRelay(t, testServerConfig())
+	resp, err := http.Get(server.URL + "/healthz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
+
+func TestRunServerShutsDown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-released
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runServer(ctx, listener, handler) }()
+
+	responses := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(released)
+
+	if status := <-responses; status != http.StatusNoContent {
+		t.Errorf("Expected the request in flight to finish, got status %d", status)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to stop")
+	}
+}
+
+func TestLoadServerConfig(t *testing.T) {
+	t.Setenv("JENKINS_KEY", "from-env")
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"Valid", "clients:\n  - name: jenkins\n    api_key: ${JENKINS_KEY}\n", ""},
+		{"No clients", "listen: \":9000\"\n", "no clients"},
+		{"No credentials", "clients:\n  - name: jenkins\n", "api_key or hmac_secret"},
+		{"Duplicate", "clients:\n  - name: a\n    api_key: x\n  - name: a\n    api_key: y\n", "twice"},
+		{"Unknown key", "clients:\n  - name: a\n    apikey: x\n", "not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "relay.yml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadServerConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if cfg.Listen != defaultServerListen || *cfg.Retries != defaultServerRetries {
+				t.Errorf("Expected defaults, got %+v", cfg)
+			}
+			if cfg.Clients[0].APIKey != "from-env" {
+				t.Errorf("Expected the API key to be read from the environment, got %q", cfg.Clients[0].APIKey)
+			}
+		})
+	}
+}
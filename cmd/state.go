@@ -0,0 +1,305 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+const (
+	// defaultStateFile is used when INPUT_STATE_FILE is not set.
+	defaultStateFile = ".slack-messages.json"
+	// defaultStateDir is used when INPUT_STATE_DIR is not set.
+	defaultStateDir = ".slack-state"
+	// channelHistoryLimit is how many recent messages the channel backend
+	// searches for a key.
+	channelHistoryLimit = 200
+)
+
+const (
+	StateBackendFile      = "file"
+	StateBackendDirectory = "directory"
+	StateBackendChannel   = "channel"
+)
+
+// MessageStore remembers where keyed messages were posted, so that later
+// steps can find them without passing ts values around.
+type MessageStore interface {
+	// Get returns the message recorded for key. It reports false when
+	// there is none.
+	Get(key string) (slack.MessageRef, bool, error)
+	Put(key string, ref slack.MessageRef) error
+	Delete(key string) error
+}
+
+// newMessageStore returns the store selected by INPUT_STATE_BACKEND.
+func newMessageStore(e *Environment) (MessageStore, error) {
+	switch e.Slack.StateBackend {
+	case "", StateBackendFile:
+		return newFileStore(e.Slack.StateFile), nil
+	case StateBackendDirectory:
+		return newDirStore(e.Slack.StateDir), nil
+	case StateBackendChannel:
+		eventType := messageKeyEventType
+		if e.Input.Metadata != nil {
+			eventType = e.Input.Metadata.EventType
+		}
+		return &channelStore{api: slackAPI, channel: e.Slack.Channel, eventType: eventType}, nil
+	}
+	return nil, fmt.Errorf("invalid state_backend %q, expected %s, %s or %s", e.Slack.StateBackend, StateBackendFile, StateBackendDirectory, StateBackendChannel)
+}
+
+// keyMarker is the block ID that marks the message posted for key. Block IDs
+// are not shown in Slack, which lets deduplication and digests find the
+// message again.
+func keyMarker(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "message-slack-" + hex.EncodeToString(sum[:16])
+}
+
+// fileStore is a MessageStore kept in a JSON file.
+type fileStore = recordFile[slack.MessageRef]
+
+// newFileStore returns a store backed by the JSON file at path.
+func newFileStore(path string) *fileStore {
+	if path == "" {
+		path = defaultStateFile
+	}
+	return &fileStore{path: path}
+}
+
+// recordFile keeps records by key in a JSON file.
+type recordFile[T any] struct {
+	path string
+}
+
+func (s *recordFile[T]) Get(key string) (T, bool, error) {
+	records, err := s.load()
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	record, ok := records[key]
+	return record, ok, nil
+}
+
+func (s *recordFile[T]) Put(key string, record T) error {
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	records[key] = record
+	return s.save(records)
+}
+
+func (s *recordFile[T]) Delete(key string) error {
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := records[key]; !ok {
+		return nil
+	}
+	delete(records, key)
+	return s.save(records)
+}
+
+func (s *recordFile[T]) load() (map[string]T, error) {
+	records := map[string]T{}
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, &records); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", s.path, err)
+	}
+	return records, nil
+}
+
+// save writes the file through a temporary file so that a failed write never
+// leaves it truncated.
+func (s *recordFile[T]) save(records map[string]T) error {
+	return writeJSONFile(s.path, records)
+}
+
+// writeJSONFile writes v through a temporary file so that a failed write
+// never leaves path truncated.
+func writeJSONFile(path string, v any) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// dirStore is a MessageStore keeping one JSON file per key in a directory,
+// meant to be saved and restored between jobs with actions/cache or as an
+// artifact. Separate files keep parallel jobs from overwriting each other's
+// keys when the artifacts are merged.
+type dirStore = recordDir[slack.MessageRef]
+
+// newDirStore returns a store backed by the directory dir.
+func newDirStore(dir string) *dirStore {
+	if dir == "" {
+		dir = defaultStateDir
+	}
+	return &dirStore{dir: dir}
+}
+
+// recordDir keeps records in a directory, one JSON file per key.
+type recordDir[T any] struct {
+	dir string
+}
+
+func (s *recordDir[T]) path(key string) string {
+	return filepath.Join(s.dir, url.PathEscape(key)+".json")
+}
+
+func (s *recordDir[T]) Get(key string) (T, bool, error) {
+	var record T
+	content, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return record, false, nil
+	}
+	if err != nil {
+		return record, false, err
+	}
+	if err := json.Unmarshal(content, &record); err != nil {
+		return record, false, fmt.Errorf("invalid state file %s: %v", s.path(key), err)
+	}
+	return record, true, nil
+}
+
+func (s *recordDir[T]) Put(key string, record T) error {
+	return writeJSONFile(s.path(key), record)
+}
+
+func (s *recordDir[T]) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// channelStore is a MessageStore without any state of its own: messages are
+// found by searching the recent channel history for the metadata set by
+// withMessageKey. Only messages of this bot are considered, so that other
+// apps cannot claim a key. It needs the channels:history scope, or
+// groups:history for private channels.
+type channelStore struct {
+	api     slackService
+	channel string
+	// eventType is the event type of the metadata of keyed messages.
+	eventType string
+	// botID is this bot's ID, looked up with auth.test on first use.
+	botID string
+}
+
+func (s *channelStore) Get(key string) (slack.MessageRef, bool, error) {
+	if s.botID == "" {
+		info, err := s.api.AuthTest()
+		if err != nil {
+			return slack.MessageRef{}, false, fmt.Errorf("error while looking up the bot: %v", err)
+		}
+		s.botID = info.BotID
+	}
+	messages, err := s.api.History(s.channel, channelHistoryLimit)
+	if err != nil {
+		return slack.MessageRef{}, false, fmt.Errorf("error searching %s for message %s: %v", s.channel, key, err)
+	}
+	for _, message := range messages {
+		if message.BotID != s.botID || message.Metadata == nil || message.Metadata.EventType != s.eventType {
+			continue
+		}
+		if value, ok := message.Metadata.EventPayload[messageKeyField].(string); ok && value == key {
+			return slack.MessageRef{Channel: s.channel, Timestamp: message.Timestamp}, true, nil
+		}
+	}
+	return slack.MessageRef{}, false, nil
+}
+
+// Put does nothing; the key is part of the metadata of the posted message.
+func (s *channelStore) Put(key string, ref slack.MessageRef) error {
+	return nil
+}
+
+// Delete does nothing; the key goes away with the message.
+func (s *channelStore) Delete(key string) error {
+	return nil
+}
+
+// HistoryMessage is a message returned by conversations.history. Of the
+// blocks only the IDs are decoded since other apps' blocks may not fit
+// slack.Block.
+type HistoryMessage struct {
+	Timestamp string `json:"ts"`
+	BotID     string `json:"bot_id"`
+	Blocks    []struct {
+		BlockID string `json:"block_id"`
+	} `json:"blocks"`
+	Metadata *MessageMetadata `json:"metadata"`
+	// ReplyCount is the number of replies in the thread of the message.
+	ReplyCount int `json:"reply_count"`
+}
+
+// hasBlock reports whether the message has a block with the ID.
+func (m HistoryMessage) hasBlock(id string) bool {
+	for _, block := range m.Blocks {
+		if block.BlockID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// History returns up to limit of the most recent messages in channel, newest
+// first.
+func (w *webAPI) History(channel string, limit int) ([]HistoryMessage, error) {
+	var messages []HistoryMessage
+	cursor := ""
+	for len(messages) < limit {
+		var response struct {
+			Messages []HistoryMessage `json:"messages"`
+			Metadata struct {
+				NextCursor string `json:"next_cursor"`
+			} `json:"response_metadata"`
+		}
+		values := url.Values{
+			"channel":              {channel},
+			"limit":                {fmt.Sprint(min(limit-len(messages), 200))},
+			"include_all_metadata": {"true"},
+		}
+		if cursor != "" {
+			values.Set("cursor", cursor)
+		}
+		if err := w.call("conversations.history", values, &response); err != nil {
+			return nil, err
+		}
+		messages = append(messages, response.Messages...)
+		cursor = response.Metadata.NextCursor
+		if cursor == "" {
+			break
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+func TestFileStore(t *testing.T) {
+	store := newFileStore(filepath.Join(t.TempDir(), "state", "messages.json"))
+
+	if _, ok, err := store.Get("deploy"); err != nil || ok {
+		t.Fatalf("Expected no message in a missing file, got %v, %v", ok, err)
+	}
+
+	ref := slack.MessageRef{Channel: "C0123", Timestamp: "1700000000.000100"}
+	if err := store.Put("deploy", ref); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	got, ok, err := store.Get("deploy")
+	if err != nil || !ok || got != ref {
+		t.Errorf("Expected %+v, got %+v, %v, %v", ref, got, ok, err)
+	}
+
+	if err := store.Delete("deploy"); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if _, ok, _ := store.Get("deploy"); ok {
+		t.Error("Expected the label to be gone after Delete")
+	}
+}
+
+func TestFileStoreInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := newFileStore(path).Get("deploy"); err == nil {
+		t.Error("Expected an error for an invalid state file")
+	}
+}
+
+func TestDirStore(t *testing.T) {
+	dir := t.TempDir()
+	store := newDirStore(dir)
+	ref := slack.MessageRef{Channel: "C0123", Timestamp: "1700000000.000100"}
+
+	if err := store.Put("deploy/abc123", ref); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deploy%2Fabc123.json")); err != nil {
+		t.Errorf("Expected one file per key, got %v", err)
+	}
+	got, ok, err := newDirStore(dir).Get("deploy/abc123")
+	if err != nil || !ok || got != ref {
+		t.Errorf("Expected %+v, got %+v, %v, %v", ref, got, ok, err)
+	}
+
+	if err := store.Delete("deploy/abc123"); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if err := store.Delete("deploy/abc123"); err != nil {
+		t.Errorf("Expected deleting a missing key to succeed, got %v", err)
+	}
+}
+
+func TestChannelStore(t *testing.T) {
+	keyed := `"metadata":{"event_type":"` + messageKeyEventType + `","event_payload":{"message_key":"deploy abc123"}}`
+	var pages int
+	api := newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.URL.Path {
+		case "/auth.test":
+			w.Write([]byte(`{"ok":true,"user_id":"U0BOT","bot_id":"B1"}`))
+			return
+		case "/conversations.history":
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.Form.Get("include_all_metadata") != "true" {
+			t.Errorf("Expected the metadata to be requested, got %v", r.Form)
+		}
+		pages++
+		if r.Form.Get("cursor") == "" {
+			w.Write([]byte(`{"ok":true,"messages":[` +
+				`{"ts":"4.0","bot_id":"B2",` + keyed + `},` +
+				`{"ts":"3.0","bot_id":"B1","metadata":{"event_type":"other","event_payload":{"message_key":"deploy abc123"}}}` +
+				`],"response_metadata":{"next_cursor":"page2"}}`))
+			return
+		}
+		w.Write([]byte(`{"ok":true,"messages":[{"ts":"2.0","bot_id":"B1",` + keyed + `}]}`))
+	})
+	store := &channelStore{api: api, channel: "C0123", eventType: messageKeyEventType}
+
+	ref, ok, err := store.Get("deploy abc123")
+	if err != nil || !ok {
+		t.Fatalf("Expected the keyed message to be found, got %v, %v", ok, err)
+	}
+	if ref.Channel != "C0123" || ref.Timestamp != "2.0" {
+		t.Errorf("Expected the message of this bot with the event type, got %+v", ref)
+	}
+	if pages != 2 {
+		t.Errorf("Expected 2 pages, got %d", pages)
+	}
+
+	if _, ok, _ := store.Get("another key"); ok {
+		t.Error("Expected no message for another key")
+	}
+}
+
+func TestChannelBackendEndToEnd(t *testing.T) {
+	fake := newFakeSlack(t)
+	// Another app's message with the same key must not be updated.
+	fake.messages["C0GENERAL"] = append(fake.messages["C0GENERAL"], fakeMessage{
+		TS:       "1600000000.000001",
+		BotID:    "B0OTHER",
+		Metadata: map[string]any{"event_type": messageKeyEventType, "event_payload": map[string]any{messageKeyField: "deploy"}},
+	})
+	env := map[string]string{
+		"INPUT_TITLE":         "Deploy",
+		"INPUT_TEXT":          "Rolling out",
+		"INPUT_SLACK_CHANNEL": "general",
+		"INPUT_MESSAGE_KEY":   "deploy",
+		"INPUT_STATE_BACKEND": StateBackendChannel,
+	}
+	if _, err := runWithFakeSlack(t, fake, env); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	env["INPUT_TEXT"] = "Done"
+	if _, err := runWithFakeSlack(t, fake, env); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+
+	posts := fake.requestsFor("chat.postMessage")
+	if len(posts) != 1 {
+		t.Fatalf("Expected a single post, got %d", len(posts))
+	}
+	updates := fake.requestsFor("chat.update")
+	if len(updates) != 1 {
+		t.Fatalf("Expected the second run to update the message, got %d updates", len(updates))
+	}
+	messages := fake.channelMessages("C0GENERAL")
+	if ts := updates[0].param("ts"); ts != messages[1].TS {
+		t.Errorf("Expected the message of this bot to be updated, got %s", ts)
+	}
+}
+
+func TestWithMessageKey(t *testing.T) {
+	keyed := withMessageKey(nil, "deploy")
+	if keyed.EventType != messageKeyEventType || keyed.EventPayload[messageKeyField] != "deploy" {
+		t.Errorf("Unexpected metadata %+v", keyed)
+	}
+
+	inputs := &MessageMetadata{EventType: "deployment", EventPayload: map[string]any{"sha": "abc123"}}
+	keyed = withMessageKey(inputs, "deploy")
+	if keyed.EventType != "deployment" || keyed.EventPayload["sha"] != "abc123" || keyed.EventPayload[messageKeyField] != "deploy" {
+		t.Errorf("Expected the key to join the metadata of the inputs, got %+v", keyed)
+	}
+	if _, ok := inputs.EventPayload[messageKeyField]; ok {
+		t.Error("Expected the metadata of the inputs to be left unchanged")
+	}
+}
+
+func TestNewMessageStore(t *testing.T) {
+	tests := []struct {
+		backend   string
+		expectErr bool
+	}{
+		{backend: ""},
+		{backend: StateBackendFile},
+		{backend: StateBackendDirectory},
+		{backend: StateBackendChannel},
+		{backend: "redis", expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.backend, func(t *testing.T) {
+			e := &Environment{}
+			e.Slack.StateBackend = tt.backend
+			_, err := newMessageStore(e)
+			if tt.expectErr != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// Permalink returns the link to the message with the ts in channel.
+func (w *webAPI) Permalink(channel string, ts string) (string, error) {
+	var response struct {
+		Permalink string `json:"permalink"`
+	}
+	err := w.call("chat.getPermalink", url.Values{"channel": {channel}, "message_ts": {ts}}, &response)
+	return response.Permalink, err
+}
+
+// permalinks returns the links to the sent messages. A link that cannot be
+// fetched is left empty, as the message was sent all the same.
+func permalinks(sent []slack.MessageRef, policy retryPolicy) []string {
+	links := make([]string, len(sent))
+	for i, ref := range sent {
+		err := policy.do("chat.getPermalink", func() error {
+			var err error
+			links[i], err = slackAPI.Permalink(ref.Channel, ref.Timestamp)
+			return err
+		})
+		if err != nil {
+			logger.Warnf("could not get the link to message %s in %s: %v", ref.Timestamp, ref.Channel, err)
+		}
+	}
+	return links
+}
+
+// reportSent sets the permalink output and adds the sent message to the job
+// summary.
+func reportSent(message slack.Message, sent []slack.MessageRef, policy retryPolicy) error {
+	links := permalinks(sent, policy)
+	if err := setOutput("permalink", strings.Join(links, ",")); err != nil {
+		return err
+	}
+	labeled := make([]slack.MessageRef, len(sent))
+	for i, ref := range sent {
+		labeled[i] = ref
+		if name := envVar.ChannelNames[ref.Channel]; name != "" {
+			labeled[i].Channel = "#" + name
+		}
+	}
+	return appendStepSummary(sentSummary(NotificationFromSlack(message, ""), labeled, links))
+}
+
+// markdownCell escapes text for a cell of a Markdown table.
+var markdownCell = strings.NewReplacer("|", `\|`, "\r\n", "<br>", "\n", "<br>")
+
+// sentSummary renders the message and where it was posted as Markdown.
+func sentSummary(n Notification, sent []slack.MessageRef, links []string) string {
+	var b strings.Builder
+	b.WriteString("### Slack message sent\n\n")
+	if n.Title != "" {
+		fmt.Fprintf(&b, "**%s**\n\n", n.Title)
+	}
+	if n.Text != "" {
+		fmt.Fprintf(&b, "%s\n\n", MarkdownFromMrkdwn(n.Text))
+	}
+	if len(n.Fields) > 0 {
+		b.WriteString("| Field | Value |\n|-------|-------|\n")
+		for _, field := range n.Fields {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownCell.Replace(field.Title), markdownCell.Replace(MarkdownFromMrkdwn(field.Value)))
+		}
+		b.WriteString("\n")
+	}
+	if len(n.Buttons) > 0 {
+		buttons := make([]string, 0, len(n.Buttons))
+		for _, button := range n.Buttons {
+			buttons = append(buttons, fmt.Sprintf("[%s](%s)", button.Text, button.URL))
+		}
+		fmt.Fprintf(&b, "%s\n\n", strings.Join(buttons, " · "))
+	}
+	b.WriteString("| Channel | Message |\n|---------|---------|\n")
+	for i, ref := range sent {
+		message := ref.Timestamp
+		if links[i] != "" {
+			message = fmt.Sprintf("[%s](%s)", ref.Timestamp, links[i])
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", ref.Channel, message)
+	}
+	return b.String()
+}
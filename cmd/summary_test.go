@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+func TestSentSummary(t *testing.T) {
+	n := Notification{
+		Title:   "Deploy",
+		Text:    "*done* in <https://example.com/run|the run>",
+		Fields:  Fields{{Title: "Env", Value: "prod"}, {Title: "Hosts", Value: "a|b\nc"}},
+		Buttons: Buttons{{Text: "Logs", URL: "https://example.com/logs"}, {Text: "App", URL: "https://example.com"}},
+	}
+	sent := []slack.MessageRef{{Channel: "#general", Timestamp: "1700000000.000001"}, {Channel: "C0OPS", Timestamp: "1700000000.000002"}}
+	links := []string{"https://fake.slack.com/archives/C0GENERAL/p1700000000000001", ""}
+
+	expected := "### Slack message sent\n\n" +
+		"**Deploy**\n\n" +
+		"**done** in [the run](https://example.com/run)\n\n" +
+		"| Field | Value |\n|-------|-------|\n" +
+		"| Env | prod |\n" +
+		"| Hosts | a\\|b<br>c |\n\n" +
+		"[Logs](https://example.com/logs) · [App](https://example.com)\n\n" +
+		"| Channel | Message |\n|---------|---------|\n" +
+		"| #general | [1700000000.000001](https://fake.slack.com/archives/C0GENERAL/p1700000000000001) |\n" +
+		"| C0OPS | 1700000000.000002 |\n"
+	if got := sentSummary(n, sent, links); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestPostWritesSummary(t *testing.T) {
+	message := map[string]string{
+		"INPUT_TITLE":         "Deploy",
+		"INPUT_TEXT":          "Deploying to production",
+		"INPUT_FIELDS":        "Env: prod",
+		"INPUT_BUTTONS":       "Logs: https://example.com/logs",
+		"INPUT_SLACK_CHANNEL": "general",
+	}
+
+	t.Run("Permalink", func(t *testing.T) {
+		summary := filepath.Join(t.TempDir(), "summary.md")
+		t.Setenv("GITHUB_STEP_SUMMARY", summary)
+		fake := newFakeSlack(t)
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		requests := fake.requestsFor("chat.getPermalink")
+		if len(requests) != 1 || requests[0].param("channel") != "C0GENERAL" || requests[0].param("message_ts") != "1700000000.000001" {
+			t.Fatalf("Expected chat.getPermalink for the sent message, got %+v", requests)
+		}
+		link := "https://fake.slack.com/archives/C0GENERAL/p1700000000000001"
+		if !strings.Contains(outputs, "permalink="+link+"\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+		content, err := os.ReadFile(summary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expected := range []string{"**Deploy**", "Deploying to production", "| Env | prod |", "[Logs](https://example.com/logs)", "| C0GENERAL | [1700000000.000001](" + link + ") |"} {
+			if !strings.Contains(string(content), expected) {
+				t.Errorf("Expected the summary to contain %q, got %s", expected, content)
+			}
+		}
+	})
+
+	t.Run("Permalink unavailable", func(t *testing.T) {
+		t.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(t.TempDir(), "summary.md"))
+		fake := newFakeSlack(t)
+		fake.failNext("chat.getPermalink", "message_not_found")
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if !strings.Contains(outputs, "permalink=\n") || !strings.Contains(outputs, "ts=1700000000.000001\n") {
+			t.Errorf("Unexpected outputs %q", outputs)
+		}
+	})
+
+	t.Run("Scheduled", func(t *testing.T) {
+		t.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(t.TempDir(), "summary.md"))
+		fake := newFakeSlack(t)
+		t.Setenv("INPUT_POST_AT", "+1h")
+		outputs, err := runWithFakeSlack(t, fake, message)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if calls := len(fake.requestsFor("chat.getPermalink")); calls != 0 || strings.Contains(outputs, "permalink") {
+			t.Errorf("Expected no permalink for a scheduled message, got %d calls and outputs %q", calls, outputs)
+		}
+	})
+}
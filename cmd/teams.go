@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Teams limits. Teams rejects messages over about 28 KB and shows at most
+// six card actions.
+const (
+	maxTeamsPayloadBytes = 28 * 1024
+	maxTeamsTextLength   = 20000
+	maxTeamsActions      = 6
+)
+
+// teamsTitleColors are the Adaptive Card text colors used for the title by
+// status.
+var teamsTitleColors = map[string]string{
+	"success": "Good",
+	"failure": "Attention",
+}
+
+// teamsProvider posts Adaptive Cards to a Teams incoming webhook or
+// Workflows webhook.
+type teamsProvider struct{}
+
+type teamsMessage struct {
+	Type        string            `json:"type"`
+	Attachments []teamsAttachment `json:"attachments"`
+}
+
+type teamsAttachment struct {
+	ContentType string       `json:"contentType"`
+	Content     adaptiveCard `json:"content"`
+}
+
+type adaptiveCard struct {
+	Schema  string            `json:"$schema"`
+	Type    string            `json:"type"`
+	Version string            `json:"version"`
+	Body    []adaptiveElement `json:"body"`
+	Actions []adaptiveAction  `json:"actions,omitempty"`
+	MSTeams map[string]string `json:"msteams"`
+}
+
+type adaptiveElement struct {
+	Type   string         `json:"type"`
+	Text   string         `json:"text,omitempty"`
+	Wrap   bool           `json:"wrap,omitempty"`
+	Weight string         `json:"weight,omitempty"`
+	Size   string         `json:"size,omitempty"`
+	Color  string         `json:"color,omitempty"`
+	Facts  []adaptiveFact `json:"facts,omitempty"`
+}
+
+type adaptiveFact struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+}
+
+type adaptiveAction struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+func (teamsProvider) Render(n Notification) (any, error) {
+	card := adaptiveCard{
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Type:    "AdaptiveCard",
+		Version: "1.4",
+		MSTeams: map[string]string{"width": "Full"},
+	}
+	card.Body = append(card.Body, adaptiveElement{
+		Type:   "TextBlock",
+		Text:   n.Title,
+		Wrap:   true,
+		Weight: "Bolder",
+		Size:   "Large",
+		Color:  teamsTitleColors[n.Status],
+	})
+	if n.Text != "" {
+		card.Body = append(card.Body, adaptiveElement{
+			Type: "TextBlock",
+			Text: truncate(MarkdownFromMrkdwn(n.Text), maxTeamsTextLength),
+			Wrap: true,
+		})
+	}
+	if len(n.Fields) > 0 {
+		facts := adaptiveElement{Type: "FactSet"}
+		for _, field := range n.Fields {
+			facts.Facts = append(facts.Facts, adaptiveFact{Title: field.Title, Value: MarkdownFromMrkdwn(field.Value)})
+		}
+		card.Body = append(card.Body, facts)
+	}
+	for i, button := range n.Buttons {
+		if i == maxTeamsActions {
+			break
+		}
+		card.Actions = append(card.Actions, adaptiveAction{Type: "Action.OpenUrl", Title: button.Text, URL: button.URL})
+	}
+
+	message := teamsMessage{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "application/vnd.microsoft.card.adaptive",
+			Content:     card,
+		}},
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxTeamsPayloadBytes {
+		return nil, fmt.Errorf("card is %d bytes, Teams accepts at most %d", len(payload), maxTeamsPayloadBytes)
+	}
+	return message, nil
+}
+
+// Send posts the card. Legacy connectors answer 200 with a body of 1 and
+// report some failures in the body instead of the status code.
+func (teamsProvider) Send(client *http.Client, webhookURL string, payload []byte) error {
+	body, err := postWebhook(client, webhookURL, payload)
+	if err != nil {
+		return err
+	}
+	if text := strings.TrimSpace(string(body)); strings.Contains(text, "failed") || strings.Contains(text, "error") {
+		return fmt.Errorf("webhook rejected the card: %s", text)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestTeamsGolden(t *testing.T) {
+	payload, err := teamsProvider{}.Render(testNotification())
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	assertGolden(t, "teams/notification", payload)
+}
+
+func TestTeamsLimits(t *testing.T) {
+	n := Notification{Title: "Deploy", Text: strings.Repeat("a", maxTeamsTextLength+100)}
+	for i := 0; i < 10; i++ {
+		n.Buttons = append(n.Buttons, Button{Text: "Link", URL: "https://example.com"})
+	}
+	rendered, err := teamsProvider{}.Render(n)
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	card := rendered.(teamsMessage).Attachments[0].Content
+	if got := len([]rune(card.Body[1].Text)); got != maxTeamsTextLength {
+		t.Errorf("Expected the text to be cut to %d characters, got %d", maxTeamsTextLength, got)
+	}
+	if len(card.Actions) != maxTeamsActions {
+		t.Errorf("Expected %d actions, got %d", maxTeamsActions, len(card.Actions))
+	}
+
+	for i := 0; i < 2000; i++ {
+		n.Fields = append(n.Fields, Field{Title: "Field", Value: "value"})
+	}
+	if _, err := (teamsProvider{}).Render(n); err == nil {
+		t.Error("Expected an error for a card over the size limit")
+	}
+}
+
+func TestTeamsSend(t *testing.T) {
+	server, payloads := webhookServer(t, http.StatusOK, "1")
+	payload, _ := json.Marshal(map[string]string{"type": "message"})
+	if err := (teamsProvider{}).Send(http.DefaultClient, server.URL, payload); err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if len(*payloads) != 1 || (*payloads)[0]["type"] != "message" {
+		t.Errorf("Unexpected payloads %v", *payloads)
+	}
+
+	failing, _ := webhookServer(t, http.StatusOK, "Webhook message delivery failed with error: Microsoft Teams endpoint returned HTTP error 413")
+	if err := (teamsProvider{}).Send(http.DefaultClient, failing.URL, payload); err == nil {
+		t.Error("Expected the failure in the body to be reported")
+	}
+}
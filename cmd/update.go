@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// messagePoster posts new messages; slack.ISlack satisfies it.
+type messagePoster interface {
+	AddFormattedMessage(channel string, message slack.Message) (slack.MessageRef, error)
+}
+
+// messageUpdater replaces the content of posted messages.
+type messageUpdater interface {
+	UpdateMessage(item slack.MessageRef, message slack.Message) error
+}
+
+// UpdateMessage replaces the text and blocks of a posted message.
+func (w *webAPI) UpdateMessage(item slack.MessageRef, message slack.Message) error {
+	return w.updateMessage(item, message, nil)
+}
+
+func (w *webAPI) updateMessage(item slack.MessageRef, message slack.Message, metadata *MessageMetadata) error {
+	message.Channel = item.Channel
+	return w.callJSON("chat.update", struct {
+		outgoingMessage
+		Timestamp string `json:"ts"`
+	}{outgoingMessage{message, metadata}, item.Timestamp}, nil)
+}
+
+// publish posts message to channel, or updates the message recorded under
+// key if there is one, and records where the message is. It reports whether
+// an existing message was updated.
+func publish(poster messagePoster, updater messageUpdater, store MessageStore, key string, channel string, message slack.Message) (slack.MessageRef, bool, error) {
+	ref, ok, err := store.Get(key)
+	if err != nil {
+		return ref, false, fmt.Errorf("error while looking up message %s: %v", key, err)
+	}
+	if ok {
+		err := updater.UpdateMessage(ref, message)
+		if err == nil {
+			return ref, true, nil
+		}
+		if !IsSlackError(err, "message_not_found") {
+			return ref, false, fmt.Errorf("error while updating message %s: %w", key, err)
+		}
+		logger.Warnf("message %s no longer exists, posting a new one", key)
+	}
+
+	ref, err = poster.AddFormattedMessage(channel, message)
+	if err != nil {
+		return ref, false, fmt.Errorf("error while sending message to slack: %w", err)
+	}
+	if err := store.Put(key, ref); err != nil {
+		return ref, false, fmt.Errorf("error while recording message %s: %v", key, err)
+	}
+	return ref, false, nil
+}
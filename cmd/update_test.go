@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// fakePublisher records posted and updated messages.
+type fakePublisher struct {
+	posted    []slack.Message
+	updated   []slack.MessageRef
+	updateErr error
+}
+
+func (f *fakePublisher) AddFormattedMessage(channel string, message slack.Message) (slack.MessageRef, error) {
+	f.posted = append(f.posted, message)
+	return slack.MessageRef{Channel: "C0123", Timestamp: "2.0"}, nil
+}
+
+func (f *fakePublisher) UpdateMessage(item slack.MessageRef, message slack.Message) error {
+	f.updated = append(f.updated, item)
+	return f.updateErr
+}
+
+func TestPublish(t *testing.T) {
+	recorded := slack.MessageRef{Channel: "C0123", Timestamp: "1.0"}
+	tests := []struct {
+		name          string
+		recorded      bool
+		updateErr     error
+		expectErr     bool
+		expectUpdated bool
+		expectTS      string
+	}{
+		{name: "First run posts", expectTS: "2.0"},
+		{name: "Same key updates", recorded: true, expectUpdated: true, expectTS: "1.0"},
+		{name: "Deleted message is posted again", recorded: true, updateErr: &SlackError{Method: "chat.update", Code: "message_not_found"}, expectTS: "2.0"},
+		{name: "Update failure", recorded: true, updateErr: errors.New("network error"), expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFileStore(filepath.Join(t.TempDir(), "messages.json"))
+			if tt.recorded {
+				store.Put("deploy abc123", recorded)
+			}
+			client := &fakePublisher{updateErr: tt.updateErr}
+
+			ref, updated, err := publish(client, client, store, "deploy abc123", "C0123", SlackMessageBuilder("Deploy", "Running", "C0123"))
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if updated != tt.expectUpdated || ref.Timestamp != tt.expectTS {
+				t.Errorf("Expected updated=%v ts=%s, got updated=%v ts=%s", tt.expectUpdated, tt.expectTS, updated, ref.Timestamp)
+			}
+			if got, _, _ := store.Get("deploy abc123"); got.Timestamp != tt.expectTS {
+				t.Errorf("Expected ts %s to be recorded, got %s", tt.expectTS, got.Timestamp)
+			}
+		})
+	}
+}
+
+func TestWebAPIUpdateMessage(t *testing.T) {
+	var body map[string]any
+	api := newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.update" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Write([]byte(`{"ok":true}`))
+	})
+
+	err := api.UpdateMessage(slack.MessageRef{Channel: "C0123", Timestamp: "1.0"}, slack.Message{Text: "Deployed"})
+	if err != nil {
+		t.Fatalf("Expected no error but got: %v", err)
+	}
+	if body["channel"] != "C0123" || body["ts"] != "1.0" || body["text"] != "Deployed" {
+		t.Errorf("Unexpected request body %v", body)
+	}
+}
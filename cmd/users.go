@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultUserMappingFile is read when INPUT_USER_MAPPING_FILE is not set.
+const defaultUserMappingFile = ".github/slack-users.yml"
+
+// ErrUserNotFound is returned when a user cannot be resolved to a Slack ID.
+var ErrUserNotFound = errors.New("user not found")
+
+// UserMapping maps GitHub users and teams to Slack.
+type UserMapping struct {
+	Version int `yaml:"version"`
+	// Users maps GitHub logins to Slack user IDs.
+	Users map[string]string `yaml:"users"`
+	// Teams maps GitHub teams, written org/team, to Slack user group
+	// handles or IDs.
+	Teams map[string]string `yaml:"teams"`
+}
+
+// LoadUserMapping reads a user mapping file. A missing file yields an empty
+// mapping.
+func LoadUserMapping(path string) (*UserMapping, error) {
+	if path == "" {
+		path = defaultUserMappingFile
+	}
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &UserMapping{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	var mapping UserMapping
+	if err := decoder.Decode(&mapping); err != nil {
+		return nil, fmt.Errorf("invalid user mapping file %s: %v", path, err)
+	}
+	if mapping.Version != configVersion {
+		return nil, fmt.Errorf("invalid user mapping file %s: unsupported version %d, expected %d", path, mapping.Version, configVersion)
+	}
+	return &mapping, nil
+}
+
+// UserResolver resolves Slack user IDs from IDs, email addresses or GitHub
+// logins listed in the mapping file.
+type UserResolver struct {
+	Mapping *UserMapping
+	// LookupByEmail returns the Slack ID of the user with the email address.
+	LookupByEmail func(email string) (string, error)
+}
+
+// Resolve returns the Slack user ID for user. It wraps ErrUserNotFound when
+// the user is unknown.
+func (r UserResolver) Resolve(user string) (string, error) {
+	user = strings.TrimPrefix(strings.TrimSpace(user), "@")
+	if isSlackID(user, "UW") {
+		return user, nil
+	}
+	if strings.Contains(user, "@") {
+		id, err := r.LookupByEmail(user)
+		if IsSlackError(err, "users_not_found") {
+			return "", fmt.Errorf("%w: no Slack user with email %s", ErrUserNotFound, user)
+		}
+		if err != nil {
+			return "", fmt.Errorf("error looking up user %s: %v", user, err)
+		}
+		return id, nil
+	}
+	if r.Mapping != nil {
+		if id, ok := r.Mapping.Users[user]; ok {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("%w: GitHub user %s has no Slack mapping", ErrUserNotFound, user)
+}
+
+// newUserResolver returns the resolver for the configured mapping file.
+func newUserResolver(e *Environment) (UserResolver, error) {
+	mapping, err := LoadUserMapping(e.Config.UserMapping)
+	if err != nil {
+		return UserResolver{}, err
+	}
+	return UserResolver{Mapping: mapping, LookupByEmail: slackAPI.LookupUserByEmail}, nil
+}
+
+// LookupUserByEmail returns the ID of the Slack user with the email address.
+func (w *webAPI) LookupUserByEmail(email string) (string, error) {
+	var response struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	}
+	if err := w.call("users.lookupByEmail", url.Values{"email": {email}}, &response); err != nil {
+		return "", err
+	}
+	return response.User.ID, nil
+}
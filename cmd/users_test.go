@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadUserMapping(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Missing file", func(t *testing.T) {
+		mapping, err := LoadUserMapping(filepath.Join(dir, "missing.yml"))
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if len(mapping.Users) != 0 {
+			t.Errorf("Expected empty mapping, got %+v", mapping)
+		}
+	})
+
+	t.Run("Mapping", func(t *testing.T) {
+		path := filepath.Join(dir, "users.yml")
+		os.WriteFile(path, []byte("version: 1\nusers:\n  octocat: U0OCTOCAT\n"), 0o644)
+		mapping, err := LoadUserMapping(path)
+		if err != nil {
+			t.Fatalf("Expected no error but got: %v", err)
+		}
+		if mapping.Users["octocat"] != "U0OCTOCAT" {
+			t.Errorf("Unexpected mapping %+v", mapping)
+		}
+	})
+
+	t.Run("Unknown field", func(t *testing.T) {
+		path := filepath.Join(dir, "unknown.yml")
+		os.WriteFile(path, []byte("version: 1\nmembers: {}\n"), 0o644)
+		if _, err := LoadUserMapping(path); err == nil {
+			t.Error("Expected an error for an unknown field")
+		}
+	})
+
+	t.Run("Unsupported version", func(t *testing.T) {
+		path := filepath.Join(dir, "version.yml")
+		os.WriteFile(path, []byte("version: 2\n"), 0o644)
+		if _, err := LoadUserMapping(path); err == nil {
+			t.Error("Expected an error for version 2")
+		}
+	})
+}
+
+func TestUserResolver(t *testing.T) {
+	api := newTestWebAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users.lookupByEmail" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		r.ParseForm()
+		if r.Form.Get("email") == "mona@example.com" {
+			w.Write([]byte(`{"ok":true,"user":{"id":"U0MONA"}}`))
+			return
+		}
+		w.Write([]byte(`{"ok":false,"error":"users_not_found"}`))
+	})
+	resolver := UserResolver{
+		Mapping:       &UserMapping{Users: map[string]string{"octocat": "U0OCTO"}},
+		LookupByEmail: api.LookupUserByEmail,
+	}
+
+	tests := []struct {
+		name     string
+		user     string
+		expected string
+		notFound bool
+	}{
+		{name: "Slack ID", user: "U0123ABCD", expected: "U0123ABCD"},
+		{name: "Email", user: "mona@example.com", expected: "U0MONA"},
+		{name: "GitHub login", user: "@octocat", expected: "U0OCTO"},
+		{name: "Unknown email", user: "nobody@example.com", notFound: true},
+		{name: "Unmapped login", user: "hubot", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, err := resolver.Resolve(tt.user)
+			if tt.notFound {
+				if !errors.Is(err, ErrUserNotFound) {
+					t.Errorf("Expected ErrUserNotFound, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error but got: %v", err)
+			}
+			if id != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, id)
+			}
+		})
+	}
+}
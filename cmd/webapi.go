@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	slack "github.com/pal-paul/go-libraries/pkg/slack"
+)
+
+// defaultSlackAPIURL is the base URL of the Slack Web API.
+const defaultSlackAPIURL = "https://slack.com/api"
+
+// webAPI calls the Slack Web API. Unlike the shared client it keeps the
+// error code returned by Slack and the network error, which tell the retries
+// and the email fallback whether Slack was unreachable, and it can be pointed
+// at another base URL, such as a fake server in tests.
+type webAPI struct {
+	token      string
+	baseURL    string
+	httpClient *http.Client
+}
+
+func newWebAPI(token string) *webAPI {
+	return &webAPI{
+		token:      token,
+		baseURL:    defaultSlackAPIURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// slackService is every Slack method the action calls. webAPI implements it
+// against the Web API; tests inject their own with withSlackClient.
+type slackService interface {
+	slack.ISlack
+	metadataSender
+	messageUpdater
+	messageDeleter
+	PostEphemeral(channel string, user string, message slack.Message) error
+	ScheduleMessage(channel string, message slack.Message, metadata *MessageMetadata, postAt time.Time) (string, error)
+	DeleteScheduledMessage(channel string, id string) error
+	History(channel string, limit int) ([]HistoryMessage, error)
+	Permalink(channel string, ts string) (string, error)
+	AuthTest() (AuthInfo, error)
+	ListChannels(cursor string) ([]Channel, string, error)
+	ChannelInfo(id string) (Channel, error)
+	JoinChannel(id string) error
+	LookupUserByEmail(email string) (string, error)
+	UserGroups() ([]UserGroup, error)
+}
+
+var _ slackService = (*webAPI)(nil)
+
+// AddFormattedMessage posts message to channel.
+func (w *webAPI) AddFormattedMessage(channel string, message slack.Message) (slack.MessageRef, error) {
+	return w.PostMessage(channel, message, nil)
+}
+
+// SlackError is an error reported by a Slack Web API method.
+type SlackError struct {
+	Method string
+	Code   string
+}
+
+func (e *SlackError) Error() string {
+	return fmt.Sprintf("slack %s failed: %s", e.Method, e.Code)
+}
+
+// IsSlackError reports whether err is a SlackError with one of the codes.
+func IsSlackError(err error, codes ...string) bool {
+	var slackErr *SlackError
+	if !errors.As(err, &slackErr) {
+		return false
+	}
+	for _, code := range codes {
+		if slackErr.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// call invokes a method with form encoded arguments, as required by most
+// read methods, and decodes the response into out.
+func (w *webAPI) call(method string, values url.Values, out any) error {
+	return w.do(method, "application/x-www-form-urlencoded", []byte(values.Encode()), out)
+}
+
+// callJSON invokes a method with a JSON body and decodes the response into
+// out.
+func (w *webAPI) callJSON(method string, body any, out any) error {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return w.do(method, "application/json; charset=utf-8", reqBody, out)
+}
+
+func (w *webAPI) do(method string, contentType string, reqBody []byte, out any) error {
+	_, err := w.doWithHeader(method, contentType, reqBody, out)
+	return err
+}
+
+// doWithHeader is do returning the response header as well, which carries
+// what the body does not, such as the scopes of the token.
+func (w *webAPI) doWithHeader(method string, contentType string, reqBody []byte, out any) (http.Header, error) {
+	req, err := http.NewRequest(http.MethodPost, w.baseURL+"/"+method, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Authorization", "Bearer "+w.token)
+	logger.Debugf("calling slack %s", method)
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling slack %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		retry, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return resp.Header, &slack.ErrRateLimit{Value: time.Duration(retry) * time.Second}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp.Header, fmt.Errorf("error calling slack %s: unexpected status code: %d", method, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.Header, err
+	}
+
+	// Only ok and error are common to every method; channel, for one, is
+	// an ID in some responses and an object in others.
+	var status struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return resp.Header, fmt.Errorf("error decoding slack %s response: %v", method, err)
+	}
+	if !status.Ok {
+		return resp.Header, &SlackError{Method: method, Code: status.Error}
+	}
+	if out == nil {
+		return resp.Header, nil
+	}
+	return resp.Header, json.Unmarshal(body, out)
+}
+
+// UserGroup is a Slack user group.
+type UserGroup struct {
+	ID     string `json:"id"`
+	Handle string `json:"handle"`
+	Name   string `json:"name"`
+}
+
+// UserGroups lists the user groups of the workspace.
+func (w *webAPI) UserGroups() ([]UserGroup, error) {
+	var response struct {
+		UserGroups []UserGroup `json:"usergroups"`
+	}
+	if err := w.call("usergroups.list", url.Values{}, &response); err != nil {
+		return nil, err
+	}
+	return response.UserGroups, nil
+}
+
+// minSlackIDLength is the shortest value taken for a Slack object ID.
+const minSlackIDLength = 5
+
+// isSlackID reports whether value looks like a Slack object ID with one of
+// the given prefixes, such as U for users or S for user groups: the prefix,
+// a digit and upper case letters or digits. Names written in upper case, such
+// as GENERAL or DEPLOYS, are not IDs.
+func isSlackID(value string, prefixes string) bool {
+	if len(value) < minSlackIDLength || !strings.ContainsRune(prefixes, rune(value[0])) {
+		return false
+	}
+	if value[1] < '0' || value[1] > '9' {
+		return false
+	}
+	for _, c := range value[2:] {
+		if !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -2,4 +2,13 @@ module github.com/pal-paul/message-slack
 
 go 1.24
 
-require github.com/pal-paul/go-libraries v1.0.0
+require (
+	github.com/pal-paul/go-libraries v1.0.0
+	github.com/tidwall/gjson v1.9.3
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/tidwall/match v1.1.1 // indirect
+	github.com/tidwall/pretty v1.2.0 // indirect
+)